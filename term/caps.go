@@ -0,0 +1,56 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import "strings"
+
+// Caps are the capabilities of a terminal type, see Capabilities.
+type Caps struct {
+	Colors           int  // Colors number of colors, 0 for none, 1<<24 for 24-bit colors
+	CursorAddressing bool // CursorAddressing the cursor can be moved around, see Screen.MoveTo
+	AltScreen        bool // AltScreen there's an alternate screen for full screen programs
+	Mouse            bool // Mouse the xterm mouse and bracketed paste modes, see ProbeFeatures
+}
+
+// knownCaps are the capabilities of common TERM values, as in their terminfo entries.
+var knownCaps = map[string]Caps{
+	"dumb":   {},
+	"vt100":  {CursorAddressing: true},
+	"vt220":  {CursorAddressing: true},
+	"linux":  {Colors: 8, CursorAddressing: true},
+	"xterm":  {Colors: 8, CursorAddressing: true, AltScreen: true, Mouse: true},
+	"screen": {Colors: 8, CursorAddressing: true, AltScreen: true, Mouse: true},
+	"tmux":   {Colors: 8, CursorAddressing: true, AltScreen: true, Mouse: true},
+	"rxvt":   {Colors: 8, CursorAddressing: true, AltScreen: true, Mouse: true},
+}
+
+// Capabilities returns the capabilities of the terminal type term, eg. os.Getenv("TERM"),
+// from a small built-in table instead of terminfo. Variants are known by their family,
+// the part before the first "-", and a "-256color" or "-direct" suffix sets Colors,
+// so "screen.xterm-256color" or "tmux-256color" work as expected.
+//
+// An unknown term gets a conservative profile, cursor addressing without colors or
+// alternate screen, an empty one is taken as dumb.
+func Capabilities(term string) Caps {
+	if term == "" {
+		return knownCaps["dumb"]
+	}
+	if c, ok := knownCaps[term]; ok {
+		return c
+	}
+	family, _, _ := strings.Cut(term, "-")
+	family, _, _ = strings.Cut(family, ".")
+	c, ok := knownCaps[family]
+	if !ok {
+		c = knownCaps["vt100"]
+	}
+	switch {
+	case strings.HasSuffix(term, "-256color"):
+		c.Colors = 256
+	case strings.HasSuffix(term, "-direct"):
+		c.Colors = 1 << 24
+	}
+	return c
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import "testing"
+
+// TestCapabilities tests the capabilities of known, variant and unknown TERM values.
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		term string
+		want Caps
+	}{
+		{"xterm-256color", Caps{Colors: 256, CursorAddressing: true, AltScreen: true, Mouse: true}},
+		{"xterm", Caps{Colors: 8, CursorAddressing: true, AltScreen: true, Mouse: true}},
+		{"screen", Caps{Colors: 8, CursorAddressing: true, AltScreen: true, Mouse: true}},
+		{"screen.xterm-256color", Caps{Colors: 256, CursorAddressing: true, AltScreen: true, Mouse: true}},
+		{"tmux-256color", Caps{Colors: 256, CursorAddressing: true, AltScreen: true, Mouse: true}},
+		{"xterm-direct", Caps{Colors: 1 << 24, CursorAddressing: true, AltScreen: true, Mouse: true}},
+		{"linux", Caps{Colors: 8, CursorAddressing: true}},
+		{"vt100", Caps{CursorAddressing: true}},
+		{"dumb", Caps{}},
+		{"", Caps{}},
+		{"weird-term", Caps{CursorAddressing: true}},
+	}
+	for _, tst := range tests {
+		if got := Capabilities(tst.term); got != tst.want {
+			t.Errorf("Capabilities(%q) got: %+v want: %+v", tst.term, got, tst.want)
+		}
+	}
+}
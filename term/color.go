@@ -34,6 +34,7 @@ available for comparisons and such.
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"strconv"
 )
@@ -83,6 +84,7 @@ const (
 	Underln   = "4"
 	Faint     = "2"
 	Bld       = "1"
+	Reverse   = "7"
 	NoMode    = "0"
 )
 
@@ -546,6 +548,54 @@ func NewColorRGB(str string, red uint8, green uint8, blue uint8) Color {
 	return Color(tstr + CSI + FgDefault + ";5;" + BgDefault + ";5;" + "m")
 }
 
+// xterm16 are the RGB values xterm uses for the 16 basic colors.
+var xterm16 = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the levels of each component in the 6x6x6 color cube, colors 16-231.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// xtermRGB returns the RGB value of color i in the xterm 256 color palette.
+func xtermRGB(i int) (r, g, b uint8) {
+	switch {
+	case i < 16:
+		return xterm16[i][0], xterm16[i][1], xterm16[i][2]
+	case i < 232:
+		i -= 16
+		return cubeLevels[i/36], cubeLevels[i/6%6], cubeLevels[i%6]
+	}
+	gray := uint8(8 + (i-232)*10)
+	return gray, gray, gray
+}
+
+// NearestColor returns the color index closest to r, g, b in the xterm palette of
+// palette colors, 256, 16 or 8, eg. for downgrading 24-bit colors (NewColorRGB) on
+// terminals without them, see Capabilities. For 256 only the color cube and grays,
+// 16-255, are matched as the basic colors are often changed by the user's theme.
+// Any other palette is taken as 8.
+func NearestColor(r, g, b uint8, palette int) int {
+	first, n := 0, 8
+	switch palette {
+	case 256:
+		first, n = 16, 256
+	case 16:
+		n = 16
+	}
+	best, bestDist := first, -1
+	for i := first; i < n; i++ {
+		pr, pg, pb := xtermRGB(i)
+		dr, dg, db := int(r)-int(pr), int(g)-int(pg), int(b)-int(pb)
+		if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
 // String is a random color stringer.
 func (c ColorRandom) String() string {
 	if !colorEnable {
@@ -634,6 +684,68 @@ func NewCombo(s string, mods ...string) Color {
 	return Color(modstr)
 }
 
+// NewAutoResetWriter returns a Writer writing to w that resets all SGR modes, "\033[0m",
+// before every newline if a color or other mode is still on at that point.
+// This keeps colors from bleeding into the next line, eg. when colored log lines
+// from several goroutines get interleaved.
+// The SGR state is tracked across Writes so escape sequences can be split between them.
+func NewAutoResetWriter(w io.Writer) io.Writer {
+	return &autoResetWriter{w: w}
+}
+
+// autoResetWriter tracks the SGR state of everything written through it.
+type autoResetWriter struct {
+	w      io.Writer
+	sgr    sgrState // sgr the SGR modes on
+	state  int      // state where in an escape sequence we are
+	params []byte   // params of the CSI sequence being read
+	buf    []byte
+}
+
+// autoResetWriter escape sequence states.
+const (
+	arText = iota // arText not in an escape sequence
+	arEsc         // arEsc got an ESC
+	arCSI         // arCSI reading a CSI sequence
+)
+
+// Write implements the io.Writer interface.
+func (a *autoResetWriter) Write(b []byte) (int, error) {
+	a.buf = a.buf[:0]
+	for _, c := range b {
+		switch a.state {
+		case arText:
+			if c == '\n' && a.sgr.on() {
+				a.buf = append(a.buf, CSI+NoMode+"m"...)
+				a.sgr = sgrState{}
+			}
+			if c == esc {
+				a.state = arEsc
+			}
+		case arEsc:
+			a.state = arText
+			if c == '[' {
+				a.state = arCSI
+				a.params = a.params[:0]
+			}
+		case arCSI:
+			if c >= 0x40 && c <= 0x7e {
+				if c == 'm' {
+					a.sgr.apply(string(a.params))
+				}
+				a.state = arText
+				break
+			}
+			a.params = append(a.params, c)
+		}
+		a.buf = append(a.buf, c)
+	}
+	if _, err := a.w.Write(a.buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
 // TestTerm tries out most of the functions in this package and return
 // a colourful string. Could be used to check what your terminal supports.
 func TestTerm() string {
@@ -5,6 +5,7 @@
 package term
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -312,6 +313,32 @@ func TestColorRGB(t *testing.T) {
 	t.Log(rstr)
 }
 
+// TestNearestColor tests matching RGB values to the xterm palettes.
+func TestNearestColor(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		palette int
+		want    int
+	}{
+		{255, 0, 0, 256, 196},
+		{0, 0, 0, 256, 16},
+		{255, 255, 255, 256, 231},
+		{95, 135, 175, 256, 67},
+		{130, 130, 130, 256, 244},
+		{250, 10, 10, 16, 9},
+		{200, 10, 10, 16, 1},
+		{128, 128, 128, 16, 8},
+		{250, 10, 10, 8, 1},
+		{255, 255, 255, 8, 7},
+		{0, 0, 255, 0, 4},
+	}
+	for _, tst := range tests {
+		if got := NearestColor(tst.r, tst.g, tst.b, tst.palette); got != tst.want {
+			t.Errorf("NearestColor(%d, %d, %d, %d) got: %d want: %d", tst.r, tst.g, tst.b, tst.palette, got, tst.want)
+		}
+	}
+}
+
 // TestTheTest runs the big test again logging it for a human to look at.
 func TestTheTest(t *testing.T) {
 	t.Log(TestTerm())
@@ -320,3 +347,33 @@ func TestTheTest(t *testing.T) {
 	t.Log(TestTerm())
 	ColorEnable()
 }
+
+// TestAutoResetWriter tests resetting SGR modes on newlines.
+func TestAutoResetWriter(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want string
+	}{
+		{[]string{"plain\ntext\n"}, "plain\ntext\n"},
+		{[]string{Red("closed").String() + "\n"}, "\x1b[31mclosed\x1b[39m\n"},
+		{[]string{"\x1b[31mopen\nnext\n"}, "\x1b[31mopen\x1b[0m\nnext\n"},
+		{[]string{"\x1b[3", "1mspl", "it\n"}, "\x1b[31msplit\x1b[0m\n"},
+		{[]string{"\x1b[1mbold\x1b[0m\n"}, "\x1b[1mbold\x1b[0m\n"},
+		{[]string{"\x1b[1m", "\n", "\n"}, "\x1b[1m\x1b[0m\n\n"},
+		{[]string{"\x1b[2Kcleared\n"}, "\x1b[2Kcleared\n"},
+		{[]string{"\x1b[1m\x1b[31mbold\x1b[39m\n"}, "\x1b[1m\x1b[31mbold\x1b[39m\x1b[0m\n"},
+		{[]string{"\x1b[4;41mline\x1b[49m", "\nnext\n"}, "\x1b[4;41mline\x1b[49m\x1b[0m\nnext\n"},
+	}
+	for _, tst := range tests {
+		var out bytes.Buffer
+		w := NewAutoResetWriter(&out)
+		for _, s := range tst.in {
+			if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+				t.Errorf("Write(%q) got: %d, %v want: %d, <nil>", s, n, err, len(s))
+			}
+		}
+		if out.String() != tst.want {
+			t.Errorf("NewAutoResetWriter(%q) got: %q want: %q", tst.in, out.String(), tst.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Escape sequence introducers and terminators.
+const (
+	esc = 0x1b // esc ESC starting all escape sequences
+	bel = 0x07 // bel BEL terminating OSC strings
+)
+
+// maxEscapeLen caps how much ReadEscapeSequence reads, OSC and DCS strings can get long.
+const maxEscapeLen = 4096
+
+var errEscapeTooLong = errors.New("escape sequence too long")
+
+// ErrTooLong is returned by ReadUntil when no delimiter came within max bytes.
+var ErrTooLong = errors.New("no delimiter within the maximum length")
+
+// ReadEscapeSequence reads the rest of an escape sequence from r after an ESC has been read.
+// The returned sequence includes the leading ESC and is one of:
+//
+//	ESC [ params intermediates final	CSI, eg. arrow keys "\033[A" or "\033[1;5C"
+//	ESC O final				SS3, eg. F1 "\033OP" or application mode arrows "\033OA"
+//	ESC ] string BEL/ST			OSC, terminated by BEL or ST ("\033\\")
+//	ESC P/X/^/_ string ST			DCS, SOS, PM and APC strings
+//	ESC char				Alt/Meta + char
+//
+// If nothing follows the ESC within timeout, or r is at EOF, just the ESC is returned,
+// that's the Esc key.
+// When running out of time in the middle of a sequence, whatever was read is returned
+// together with ErrTimeout.
+//
+// Timeouts are only supported when r is an *os.File, other readers are read blocking.
+func ReadEscapeSequence(r io.Reader, timeout time.Duration) ([]byte, error) {
+	seq := []byte{esc}
+	b, err := nextByte(r, timeout)
+	if err == ErrTimeout || err == io.EOF {
+		return seq, nil
+	}
+	if err != nil {
+		return seq, err
+	}
+	seq = append(seq, b)
+	switch b {
+	case '[':
+		// CSI, parameter and intermediate bytes until a final byte in 0x40-0x7E.
+		for {
+			if b, err = nextByte(r, timeout); err != nil {
+				return seq, err
+			}
+			seq = append(seq, b)
+			if b >= 0x40 && b <= 0x7e {
+				return seq, nil
+			}
+			if len(seq) >= maxEscapeLen {
+				return seq, errEscapeTooLong
+			}
+		}
+	case 'O':
+		// SS3, a single final byte.
+		if b, err = nextByte(r, timeout); err != nil {
+			return seq, err
+		}
+		return append(seq, b), nil
+	case ']', 'P', 'X', '^', '_':
+		// Control strings until the String Terminator, OSC can also end with BEL.
+		for {
+			if b, err = nextByte(r, timeout); err != nil {
+				return seq, err
+			}
+			seq = append(seq, b)
+			if b == bel && seq[1] == ']' {
+				return seq, nil
+			}
+			if b == '\\' && seq[len(seq)-2] == esc {
+				return seq, nil
+			}
+			if len(seq) >= maxEscapeLen {
+				return seq, errEscapeTooLong
+			}
+		}
+	}
+	return seq, nil
+}
+
+// nextByte reads a single byte from r.
+// If r is an *os.File it waits at most timeout for it and returns ErrTimeout if nothing arrived.
+func nextByte(r io.Reader, timeout time.Duration) (byte, error) {
+	if f, ok := r.(*os.File); ok {
+		ok, err := pollIn(f, timeout)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, ErrTimeout
+		}
+	}
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// ReadUntil reads from r until one of the delims, for record and line protocols over a
+// PTY or serial line. Returns the data read, without the delimiter, and the delimiter
+// that ended it.
+//
+// ErrTooLong is returned with the first max bytes when there's no delimiter among them,
+// max <= 0 means no limit. ErrTimeout is returned with what was read when the whole
+// read takes longer than timeout, only supported when r is an *os.File, see
+// ReadEscapeSequence. At the end of r what was read is returned with io.EOF.
+//
+// r is read a byte at a time, so nothing after the delimiter is consumed.
+func ReadUntil(r io.Reader, delims []byte, max int, timeout time.Duration) ([]byte, byte, error) {
+	end := time.Now().Add(timeout)
+	var data []byte
+	for max <= 0 || len(data) < max {
+		b, err := nextByte(r, time.Until(end))
+		if err != nil {
+			return data, 0, err
+		}
+		if bytes.IndexByte(delims, b) >= 0 {
+			return data, b, nil
+		}
+		data = append(data, b)
+	}
+	return data, 0, ErrTooLong
+}
+
+// CSIResponse is a parsed CSI sequence, eg. a terminal's reply to a query.
+//
+// The cursor position report "\033[24;80R" is Params [24 80], Final 'R' and the
+// primary device attributes "\033[?62;22c" Private '?', Params [62 22] and Final 'c'.
+type CSIResponse struct {
+	Private      byte   // Private the private parameter marker, one of "<=>?", or 0
+	Params       []int  // Params the numeric parameters, an empty parameter is 0
+	Intermediate []byte // Intermediate the intermediate bytes, 0x20-0x2f, before Final
+	Final        byte   // Final the final byte, 0x40-0x7e
+}
+
+// ParseCSI parses the CSI sequence seq, as read by ReadEscapeSequence, leading ESC [ included.
+// Parameters with sub-parameters, "1:2", are returned as the first of them.
+func ParseCSI(seq []byte) (CSIResponse, error) {
+	var resp CSIResponse
+	if len(seq) < 3 || seq[0] != esc || seq[1] != '[' {
+		return resp, errors.New("not a CSI sequence")
+	}
+	resp.Final = seq[len(seq)-1]
+	if resp.Final < 0x40 || resp.Final > 0x7e {
+		return resp, errors.New("CSI sequence without a final byte")
+	}
+	body := seq[2 : len(seq)-1]
+	if len(body) > 0 && body[0] >= '<' && body[0] <= '?' {
+		resp.Private, body = body[0], body[1:]
+	}
+	i := len(body)
+	for i > 0 && body[i-1] >= 0x20 && body[i-1] <= 0x2f {
+		i--
+	}
+	if i < len(body) {
+		resp.Intermediate = append([]byte(nil), body[i:]...)
+	}
+	body = body[:i]
+	if len(body) == 0 {
+		return resp, nil
+	}
+	for _, p := range strings.Split(string(body), ";") {
+		p, _, _ = strings.Cut(p, ":")
+		if p == "" {
+			resp.Params = append(resp.Params, 0)
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return resp, errors.New("bad CSI parameter: " + strconv.Quote(p))
+		}
+		resp.Params = append(resp.Params, n)
+	}
+	return resp, nil
+}
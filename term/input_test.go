@@ -0,0 +1,151 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadEscapeSequence tests reading the different escape sequence types.
+func TestReadEscapeSequence(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		rest string
+	}{
+		{"[A", "\033[A", ""},
+		{"[1;5C", "\033[1;5C", "rest"},
+		{"[200~", "\033[200~", "paste"},
+		{"OP", "\033OP", ""},
+		{"OA", "\033OA", "B"},
+		{"]0;title\a", "\033]0;title\a", ""},
+		{"]0;title\033\\", "\033]0;title\033\\", "x"},
+		{"P1$r0m\033\\", "\033P1$r0m\033\\", ""},
+		{"x", "\033x", ""},
+	}
+	for _, tst := range tests {
+		r := strings.NewReader(tst.in + tst.rest)
+		got, err := ReadEscapeSequence(r, time.Second)
+		if err != nil {
+			t.Errorf("ReadEscapeSequence(%q) failed: %v", tst.in, err)
+			continue
+		}
+		if string(got) != tst.want {
+			t.Errorf("ReadEscapeSequence(%q) got: %q want: %q", tst.in, got, tst.want)
+		}
+		if r.Len() != len(tst.rest) {
+			t.Errorf("ReadEscapeSequence(%q) left %d bytes want: %d", tst.in, r.Len(), len(tst.rest))
+		}
+	}
+	if _, err := ReadEscapeSequence(strings.NewReader("[1;"), time.Second); err == nil {
+		t.Error("ReadEscapeSequence of a truncated sequence got: <nil> want: error")
+	}
+}
+
+// TestReadEscapeSequenceTimeout tests that a lone ESC is returned after the timeout.
+func TestReadEscapeSequenceTimeout(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Raw()
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got, err := ReadEscapeSequence(tty.Slave, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("ReadEscapeSequence failed: %v", err)
+	}
+	if string(got) != "\033" {
+		t.Errorf("ReadEscapeSequence with no input got: %q want: %q", got, "\033")
+	}
+	if _, err := tty.Master.Write([]byte("[B")); err != nil {
+		t.Fatalf("Write to master failed: %v", err)
+	}
+	if got, err = ReadEscapeSequence(tty.Slave, time.Second); err != nil {
+		t.Fatalf("ReadEscapeSequence failed: %v", err)
+	}
+	if string(got) != "\033[B" {
+		t.Errorf("ReadEscapeSequence got: %q want: %q", got, "\033[B")
+	}
+}
+
+// TestReadUntil tests reading up to the delimiters, the length limit and the timeout.
+func TestReadUntil(t *testing.T) {
+	tests := []struct {
+		in    string
+		max   int
+		want  string
+		delim byte
+		err   error
+		rest  string
+	}{
+		{"abc\ndef", 0, "abc", '\n', nil, "def"},
+		{"ab;c\n", 10, "ab", ';', nil, "c\n"},
+		{"\n", 10, "", '\n', nil, ""},
+		{"abcdef\n", 3, "abc", 0, ErrTooLong, "def\n"},
+		{"abc", 0, "abc", 0, io.EOF, ""},
+	}
+	for _, tst := range tests {
+		r := strings.NewReader(tst.in)
+		got, delim, err := ReadUntil(r, []byte("\n;"), tst.max, time.Second)
+		if string(got) != tst.want || delim != tst.delim || err != tst.err {
+			t.Errorf("ReadUntil(%q, %d) got: %q, %q, %v want: %q, %q, %v", tst.in, tst.max, got, delim, err, tst.want, tst.delim, tst.err)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != tst.rest {
+			t.Errorf("ReadUntil(%q, %d) left: %q want: %q", tst.in, tst.max, rest, tst.rest)
+		}
+	}
+	tty := rawPTY(t)
+	defer tty.Close()
+	tty.Master.Write([]byte("partial"))
+	got, _, err := ReadUntil(tty.Slave, []byte("\n"), 0, 20*time.Millisecond)
+	if string(got) != "partial" || err != ErrTimeout {
+		t.Errorf("ReadUntil without delimiter got: %q, %v want: %q, %v", got, err, "partial", ErrTimeout)
+	}
+}
+
+// TestParseCSI tests parsing CSI sequences.
+func TestParseCSI(t *testing.T) {
+	tests := []struct {
+		in   string
+		want CSIResponse
+	}{
+		{"\033[A", CSIResponse{Final: 'A'}},
+		{"\033[24;80R", CSIResponse{Params: []int{24, 80}, Final: 'R'}},
+		{"\033[?62;22;1;4c", CSIResponse{Private: '?', Params: []int{62, 22, 1, 4}, Final: 'c'}},
+		{"\033[>41;354;0c", CSIResponse{Private: '>', Params: []int{41, 354, 0}, Final: 'c'}},
+		{"\033[;5H", CSIResponse{Params: []int{0, 5}, Final: 'H'}},
+		{"\033[1;;3m", CSIResponse{Params: []int{1, 0, 3}, Final: 'm'}},
+		{"\033[2 q", CSIResponse{Params: []int{2}, Intermediate: []byte(" "), Final: 'q'}},
+		{"\033[?1;2$y", CSIResponse{Private: '?', Params: []int{1, 2}, Intermediate: []byte("$"), Final: 'y'}},
+		{"\033[38:2:1:2:3m", CSIResponse{Params: []int{38}, Final: 'm'}},
+		{"\033[8;24;80t", CSIResponse{Params: []int{8, 24, 80}, Final: 't'}},
+	}
+	for _, tst := range tests {
+		got, err := ParseCSI([]byte(tst.in))
+		if err != nil {
+			t.Errorf("ParseCSI(%q) failed: %v", tst.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("ParseCSI(%q) got: %+v want: %+v", tst.in, got, tst.want)
+		}
+	}
+	for _, bad := range []string{"", "\033", "\033[", "\033OA", "[1A", "\033[1;", "\033[1x;2R"} {
+		if _, err := ParseCSI([]byte(bad)); err == nil {
+			t.Errorf("ParseCSI(%q) got: <nil> want: error", bad)
+		}
+	}
+}
@@ -0,0 +1,669 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// KeyCode identifies the keys a KeyReader decodes.
+type KeyCode int
+
+// Key codes.
+const (
+	KeyRune      KeyCode = iota // KeyRune A character, see Key.Rune
+	KeyUnknown                  // KeyUnknown Escape sequence that could not be decoded
+	KeyEsc                      // KeyEsc The Esc key on its own
+	KeyEnter                    // KeyEnter Enter / Return
+	KeyTab                      // KeyTab Tab, with ModShift for backtab
+	KeyBackspace                // KeyBackspace Backspace, both ^H and ^?
+	KeyUp                       // KeyUp Arrow up
+	KeyDown                     // KeyDown Arrow down
+	KeyRight                    // KeyRight Arrow right
+	KeyLeft                     // KeyLeft Arrow left
+	KeyHome                     // KeyHome Home
+	KeyEnd                      // KeyEnd End
+	KeyInsert                   // KeyInsert Insert
+	KeyDelete                   // KeyDelete Delete
+	KeyPgUp                     // KeyPgUp Page up
+	KeyPgDown                   // KeyPgDown Page down
+	KeyF1                       // KeyF1 Function keys F1 - F12
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+	KeyFocusIn    // KeyFocusIn The terminal got focus, see EnableFocusReporting
+	KeyFocusOut   // KeyFocusOut The terminal lost focus
+	KeyPasteStart // KeyPasteStart Start of pasted text, see EnableBracketedPaste
+	KeyPasteEnd   // KeyPasteEnd End of pasted text
+	KeyPaste      // KeyPaste A whole paste, the text in Key.Text, see KeyReader.ReadPastes
+	KeyKP0        // KeyKP0 Keypad keys 0 - 9 in application mode, see SetKeypadApplicationMode
+	KeyKP1
+	KeyKP2
+	KeyKP3
+	KeyKP4
+	KeyKP5
+	KeyKP6
+	KeyKP7
+	KeyKP8
+	KeyKP9
+	KeyKPEnter    // KeyKPEnter Keypad Enter
+	KeyKPDecimal  // KeyKPDecimal Keypad .
+	KeyKPPlus     // KeyKPPlus Keypad +
+	KeyKPMinus    // KeyKPMinus Keypad -
+	KeyKPMultiply // KeyKPMultiply Keypad *
+	KeyKPDivide   // KeyKPDivide Keypad /
+	KeyKPComma    // KeyKPComma Keypad ,
+	KeyKPEqual    // KeyKPEqual Keypad =
+)
+
+// KeyMod modifier keys held down with a key.
+type KeyMod int
+
+// Modifiers, same bits as the xterm and kitty modifier parameters minus one.
+// Only the kitty keyboard protocol reports the ones after ModCtrl, see EnableKittyKeyboard.
+// ModCapsLock and ModNumLock are lock states rather than keys held down, they go
+// in Key.Locks so checks like k.Mod == 0 aren't thrown by Caps Lock being on.
+const (
+	ModShift    KeyMod = 1 << iota // ModShift Shift
+	ModAlt                         // ModAlt Alt / Meta
+	ModCtrl                        // ModCtrl Control
+	ModSuper                       // ModSuper Super, the Windows / Command key
+	ModHyper                       // ModHyper Hyper
+	ModMeta                        // ModMeta Meta, when told apart from Alt
+	ModCapsLock                    // ModCapsLock Caps Lock is on
+	ModNumLock                     // ModNumLock Num Lock is on
+)
+
+// modLocks the modifier bits for lock states, see Key.Locks.
+const modLocks = ModCapsLock | ModNumLock
+
+// KeyEvent what happened to a key. Only the kitty keyboard protocol reports
+// repeats and releases, see KittyReportEvents, everything else is a KeyPress.
+type KeyEvent int
+
+// Key events.
+const (
+	KeyPress   KeyEvent = iota // KeyPress The key was pressed
+	KeyRepeat                  // KeyRepeat The key is held down and repeating
+	KeyRelease                 // KeyRelease The key was released
+)
+
+// Key is a decoded key press.
+// Control characters are KeyRune with ModCtrl, eg. ^C is Key{Code: KeyRune, Rune: 'c', Mod: ModCtrl}.
+type Key struct {
+	Code      KeyCode  // Code which key
+	Rune      rune     // Rune character for KeyRune
+	Mod       KeyMod   // Mod modifiers held down
+	Locks     KeyMod   // Locks ModCapsLock and ModNumLock when reported as on
+	Event     KeyEvent // Event press, repeat or release
+	Text      string   // Text the pasted text for KeyPaste
+	Truncated bool     // Truncated the paste was cut short at KeyReader.MaxPaste
+}
+
+// DefaultEscTimeout how long a KeyReader waits for the rest of an escape sequence
+// before deciding it's the Esc key on its own.
+const DefaultEscTimeout = 50 * time.Millisecond
+
+// DefaultMaxPaste the most bytes of a paste a KeyReader keeps, see KeyReader.MaxPaste.
+const DefaultMaxPaste = 1 << 20
+
+// pasteChunkSize the size of the chunks handed to KeyReader.OnPasteChunk.
+const pasteChunkSize = 4096
+
+// KeyReader decodes key presses, including the escape sequences special keys send,
+// from a terminal in raw mode.
+//
+// Both the normal (CSI, "\033[A") and application (SS3, "\033OA") cursor key modes
+// decode to the same keys, see SetApplicationCursorKeys.
+type KeyReader struct {
+	// EscTimeout how long to wait for the rest of an escape sequence after an ESC.
+	// Only used when reading from an *os.File.
+	EscTimeout time.Duration
+	// ReadPastes makes a bracketed paste read as a single KeyPaste, the pasted text
+	// in Key.Text as ReadPaste returns it, instead of a KeyPasteStart followed by
+	// the text, see EnableBracketedPaste.
+	ReadPastes bool
+	// MaxPaste the most bytes of a paste kept, DefaultMaxPaste to start with and
+	// no limit when negative. The rest of a longer paste is read and thrown away,
+	// a KeyPaste of it has Truncated set. Keeps a giant paste, eg. sent to a server,
+	// from taking up all the memory.
+	MaxPaste int
+	// OnPasteChunk when set gets pastes in chunks as they're read, instead of them
+	// being kept, whatever their size. The chunks are the bytes pasted as is, line
+	// endings not changed, and only valid during the call. The text of the KeyPaste,
+	// or what ReadPaste returns, is then empty.
+	OnPasteChunk func(chunk []byte)
+
+	r    io.Reader
+	rest []byte // rest read from r past the end of a paste, the next to decode
+}
+
+// NewKeyReader returns a KeyReader reading from r, normally a terminal set to Raw().
+func NewKeyReader(r io.Reader) *KeyReader {
+	return &KeyReader{EscTimeout: DefaultEscTimeout, MaxPaste: DefaultMaxPaste, r: r}
+}
+
+// ReadKey reads and decodes the next key press.
+func (kr *KeyReader) ReadKey() (Key, error) {
+	var b [utf8.UTFMax]byte
+	k, _, err := kr.readKey(&b)
+	return k, err
+}
+
+// ReadKeyRaw is ReadKey also returning the bytes the key was decoded from, as
+// they were read. A multiplexer can act on the keys it knows and forward the
+// rest, eg. KeyUnknown, to the child unchanged. For KeyPasteStart only the start
+// sequence is returned, the pasted text is read by ReadPaste, for KeyPaste it's the
+// paste. The exception is a Truncated KeyPaste: only the part kept is returned,
+// followed by the closing "\033[201~", so it forwards as a shorter, well formed
+// paste rather than MaxPaste having to keep all of it.
+func (kr *KeyReader) ReadKeyRaw() (Key, []byte, error) {
+	var b [utf8.UTFMax]byte
+	k, raw, err := kr.readKey(&b)
+	if err != nil {
+		return Key{}, nil, err
+	}
+	return k, append([]byte(nil), raw...), nil
+}
+
+// readKey reads and decodes the next key press, returning the bytes read,
+// those of a character in b.
+func (kr *KeyReader) readKey(b *[utf8.UTFMax]byte) (Key, []byte, error) {
+	if _, err := io.ReadFull(kr.src(), b[:1]); err != nil {
+		return Key{}, nil, err
+	}
+	switch {
+	case b[0] == esc:
+		seq, err := ReadEscapeSequence(kr.src(), kr.EscTimeout)
+		if err != nil {
+			return Key{}, nil, err
+		}
+		k := decodeEscape(seq)
+		if k.Code != KeyPasteStart || !kr.ReadPastes {
+			return k, seq, nil
+		}
+		paste, truncated, err := kr.readPaste()
+		if err != nil {
+			return Key{}, nil, err
+		}
+		k = Key{Code: KeyPaste, Text: pasteText(paste), Truncated: truncated}
+		return k, append(append(seq, paste...), pasteEnd...), nil
+	case b[0] < utf8.RuneSelf:
+		return byteKey(b[0]), b[:1], nil
+	}
+	// Multibyte UTF-8, read in the rest of it.
+	n := 2
+	switch {
+	case b[0] >= 0xf0:
+		n = 4
+	case b[0] >= 0xe0:
+		n = 3
+	}
+	if _, err := io.ReadFull(kr.src(), b[1:n]); err != nil {
+		return Key{}, nil, err
+	}
+	r, _ := utf8.DecodeRune(b[:n])
+	return Key{Code: KeyRune, Rune: r}, b[:n], nil
+}
+
+// src returns the reader to decode from, r itself unless there's a rest left from
+// a paste. Keeps r an *os.File for ReadEscapeSequence's timeouts when it can.
+func (kr *KeyReader) src() io.Reader {
+	if len(kr.rest) > 0 {
+		return restReader{kr}
+	}
+	return kr.r
+}
+
+// restReader reads the KeyReader rest, then r.
+type restReader struct {
+	kr *KeyReader
+}
+
+// Read implements the io.Reader interface.
+func (rr restReader) Read(b []byte) (int, error) {
+	return rr.kr.read(b)
+}
+
+// read reads from the rest, or r when there's none left.
+func (kr *KeyReader) read(b []byte) (int, error) {
+	if len(kr.rest) == 0 {
+		return kr.r.Read(b)
+	}
+	n := copy(b, kr.rest)
+	kr.rest = kr.rest[n:]
+	return n, nil
+}
+
+// Drain reads and decodes the keys already waiting to be read, returning as soon
+// as there's no more input right away. Lets a UI handle the input of a frame in one go:
+//
+//	for range ticker.C {
+//		keys, err := kr.Drain()
+//		for _, k := range keys {
+//			handle(k)
+//		}
+//		...
+//		draw()
+//	}
+//
+// Waiting input is polled for when reading from an *os.File and taken from Len
+// for readers having it, eg. *bytes.Reader, there's none for other readers.
+// Only the rest of a key already started, eg. an escape sequence, is waited for,
+// see EscTimeout. The keys decoded up to an error are returned with it.
+func (kr *KeyReader) Drain() ([]Key, error) {
+	var keys []Key
+	for {
+		ok, err := kr.waiting()
+		if err != nil || !ok {
+			return keys, err
+		}
+		k, err := kr.ReadKey()
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, k)
+	}
+}
+
+// waiting reports whether there's input waiting to be read.
+func (kr *KeyReader) waiting() (bool, error) {
+	if len(kr.rest) > 0 {
+		return true, nil
+	}
+	switch r := kr.r.(type) {
+	case *os.File:
+		return pollIn(r, 0)
+	case interface{ Len() int }:
+		return r.Len() > 0, nil
+	}
+	return false, nil
+}
+
+// pasteEnd ends the text pasted in bracketed paste mode.
+const pasteEnd = CSI + "201~"
+
+// ReadPaste reads the pasted text following a KeyPasteStart up to and
+// including the closing "\033[201~". The text is returned as is apart
+// from line endings, "\r\n" and "\r" are turned into "\n".
+// Text beyond MaxPaste is thrown away, see ReadPastes for knowing it was.
+func (kr *KeyReader) ReadPaste() (string, error) {
+	paste, _, err := kr.readPaste()
+	if err != nil {
+		return "", err
+	}
+	return pasteText(paste), nil
+}
+
+// readPaste reads the paste up to and including the closing "\033[201~",
+// returning what's kept of it as is, without the closing sequence, see MaxPaste
+// and OnPasteChunk. It's read in blocks, what comes after the closing sequence
+// is put in rest for the keys following.
+func (kr *KeyReader) readPaste() (paste []byte, truncated bool, err error) {
+	var chunk, tail []byte
+	block := make([]byte, pasteChunkSize)
+	for {
+		n, err := kr.read(block)
+		for i, b := range block[:n] {
+			// tail holds what could be the start of the closing sequence.
+			tail = append(tail, b)
+			if len(tail) < len(pasteEnd) {
+				continue
+			}
+			if string(tail) == pasteEnd {
+				kr.rest = append(append([]byte(nil), block[i+1:n]...), kr.rest...)
+				if len(chunk) > 0 {
+					kr.OnPasteChunk(chunk)
+				}
+				return paste, truncated, nil
+			}
+			c := tail[0]
+			tail = append(tail[:0], tail[1:]...)
+			switch {
+			case kr.OnPasteChunk != nil:
+				if chunk = append(chunk, c); len(chunk) == pasteChunkSize {
+					kr.OnPasteChunk(chunk)
+					chunk = chunk[:0]
+				}
+			case kr.MaxPaste < 0 || len(paste) < kr.MaxPaste:
+				paste = append(paste, c)
+			default:
+				truncated = true
+			}
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// pasteText returns the text of a paste read by readPaste.
+func pasteText(paste []byte) string {
+	return strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(string(paste))
+}
+
+// byteKey decodes single byte keys.
+func byteKey(b byte) Key {
+	switch {
+	case isLineEnd(b):
+		return Key{Code: KeyEnter}
+	case b == '\t':
+		return Key{Code: KeyTab}
+	case b == EraseBS, b == EraseDEL:
+		return Key{Code: KeyBackspace}
+	case b == esc:
+		return Key{Code: KeyEsc}
+	case b >= 1 && b <= 26:
+		return Key{Code: KeyRune, Rune: rune(b) + 'a' - 1, Mod: ModCtrl}
+	case b < 0x20:
+		return Key{Code: KeyRune, Rune: rune(b) + '@', Mod: ModCtrl}
+	}
+	return Key{Code: KeyRune, Rune: rune(b)}
+}
+
+// Keys for the final byte of CSI and SS3 sequences.
+var finalKeys = map[byte]KeyCode{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// Keys for the final byte of the SS3 sequences the keypad sends in application mode.
+var keypadKeys = map[byte]KeyCode{
+	'p': KeyKP0,
+	'q': KeyKP1,
+	'r': KeyKP2,
+	's': KeyKP3,
+	't': KeyKP4,
+	'u': KeyKP5,
+	'v': KeyKP6,
+	'w': KeyKP7,
+	'x': KeyKP8,
+	'y': KeyKP9,
+	'M': KeyKPEnter,
+	'n': KeyKPDecimal,
+	'k': KeyKPPlus,
+	'm': KeyKPMinus,
+	'j': KeyKPMultiply,
+	'o': KeyKPDivide,
+	'l': KeyKPComma,
+	'X': KeyKPEqual,
+}
+
+// Keys for the "\033[<n>~" sequences.
+var tildeKeys = map[int]KeyCode{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPgUp,
+	6:  KeyPgDown,
+	7:  KeyHome,
+	8:  KeyEnd,
+	11: KeyF1,
+	12: KeyF2,
+	13: KeyF3,
+	14: KeyF4,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+// decodeEscape decodes an escape sequence read by ReadEscapeSequence.
+func decodeEscape(seq []byte) Key {
+	if len(seq) == 1 {
+		return Key{Code: KeyEsc}
+	}
+	switch seq[1] {
+	case '[':
+		csi, err := ParseCSI(seq)
+		if err != nil || csi.Private != 0 || len(csi.Intermediate) > 0 {
+			return Key{Code: KeyUnknown}
+		}
+		params, final := csi.Params, csi.Final
+		if final == 'u' {
+			return kittyKey(csiSubParams(seq))
+		}
+		k := Key{Code: KeyUnknown}
+		switch {
+		case final == '~' && len(params) == 1 && params[0] == 200:
+			k.Code = KeyPasteStart
+		case final == '~' && len(params) == 1 && params[0] == 201:
+			k.Code = KeyPasteEnd
+		case final == '~' && len(params) > 0:
+			if code, ok := tildeKeys[params[0]]; ok {
+				k.Code = code
+			}
+		case final == 'Z':
+			k = Key{Code: KeyTab, Mod: ModShift}
+		case final == 'I' && len(params) == 0:
+			k.Code = KeyFocusIn
+		case final == 'O' && len(params) == 0:
+			k.Code = KeyFocusOut
+		default:
+			if code, ok := finalKeys[final]; ok {
+				k.Code = code
+			}
+		}
+		if len(params) > 1 && params[1] > 1 && k.Code != KeyUnknown {
+			k.setMods(params[1])
+		}
+		if sub := csiSubParams(seq); len(sub) > 1 && len(sub[1]) > 1 && k.Code != KeyUnknown {
+			// Event type of the kitty protocol, "\033[1;1:3A" for releasing up.
+			k.Event = kittyEvent(sub[1][1])
+		}
+		return k
+	case 'O':
+		if len(seq) == 3 {
+			if code, ok := finalKeys[seq[2]]; ok {
+				return Key{Code: code}
+			}
+			if code, ok := keypadKeys[seq[2]]; ok {
+				return Key{Code: code}
+			}
+		}
+		return Key{Code: KeyUnknown}
+	}
+	if len(seq) != 2 {
+		return Key{Code: KeyUnknown}
+	}
+	k := byteKey(seq[1])
+	if seq[1] >= utf8.RuneSelf {
+		k = Key{Code: KeyUnknown}
+	}
+	k.Mod |= ModAlt
+	return k
+}
+
+// SetApplicationCursorKeys turns application cursor keys mode on or off.
+// In application mode, "\033[?1h", the arrow keys send SS3 sequences, eg. "\033OA" for up,
+// instead of the normal CSI ones, "\033[A". Full-screen applications often turn it on,
+// a KeyReader decodes the keys the same in both modes.
+func SetApplicationCursorKeys(w io.Writer, on bool) error {
+	seq := CSI + "?1l"
+	if on {
+		seq = CSI + "?1h"
+	}
+	_, err := io.WriteString(w, seq)
+	return err
+}
+
+// SetKeypadApplicationMode turns keypad application mode on, "\033=" (DECKPAM),
+// or off, "\033>" (DECKPNM). In application mode the numeric keypad sends SS3
+// sequences, eg. "\033Op" for 0 and "\033OM" for Enter, a KeyReader decodes them
+// as KeyKP0 to KeyKP9, KeyKPEnter and so on. Off, the keypad sends the characters
+// on its keys.
+func SetKeypadApplicationMode(w io.Writer, on bool) error {
+	seq := "\033>"
+	if on {
+		seq = "\033="
+	}
+	_, err := io.WriteString(w, seq)
+	return err
+}
+
+// EnableFocusReporting asks the terminal to report getting and losing focus,
+// "\033[?1004h". A KeyReader returns the reports as KeyFocusIn and KeyFocusOut.
+func EnableFocusReporting(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"?1004h")
+	return err
+}
+
+// DisableFocusReporting turns the focus reports back off, "\033[?1004l".
+func DisableFocusReporting(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"?1004l")
+	return err
+}
+
+// EnableBracketedPaste asks the terminal to mark pasted text, "\033[?2004h".
+// Pastes are then sent wrapped in "\033[200~" and "\033[201~", a KeyReader
+// returns the first as KeyPasteStart and ReadPaste reads the text, or the lot as
+// a KeyPaste with ReadPastes set.
+func EnableBracketedPaste(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"?2004h")
+	return err
+}
+
+// DisableBracketedPaste turns bracketed paste back off, "\033[?2004l".
+func DisableBracketedPaste(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"?2004l")
+	return err
+}
+
+// Flags for EnableKittyKeyboard, see the kitty keyboard protocol documentation.
+const (
+	KittyDisambiguate     = 1 << iota // KittyDisambiguate Send CSI u for keys that are ambiguous otherwise, eg. Esc and ^I / Tab
+	KittyReportEvents                 // KittyReportEvents Report repeats and releases, see Key.Event
+	KittyReportAlternates             // KittyReportAlternates Report shifted and base layout keys
+	KittyReportAllKeys                // KittyReportAllKeys Send all keys, also plain text ones, as escape sequences
+	KittyReportText                   // KittyReportText Send the text of the keys with KittyReportAllKeys
+)
+
+// EnableKittyKeyboard turns on the kitty keyboard protocol with the Kitty flags,
+// "\033[>flagsu". The terminal then sends keys as "\033[code;modifiers:eventu" which
+// a KeyReader decodes with all the modifiers and, with KittyReportEvents, the releases.
+// Terminals not knowing the protocol ignore it and keep sending the legacy sequences,
+// which are decoded as always.
+func EnableKittyKeyboard(w io.Writer, flags int) error {
+	_, err := io.WriteString(w, CSI+">"+strconv.Itoa(flags)+"u")
+	return err
+}
+
+// DisableKittyKeyboard goes back to the keyboard mode from before EnableKittyKeyboard, "\033[<u".
+func DisableKittyKeyboard(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"<u")
+	return err
+}
+
+// inputModesOff turns off the xterm mouse modes, SGR mouse encoding, bracketed
+// paste, focus reporting and application cursor keys.
+const inputModesOff = CSI + "?1000l" + CSI + "?1002l" + CSI + "?1003l" + CSI + "?1006l" +
+	CSI + "?2004l" + CSI + "?1004l" + CSI + "?1l"
+
+// ResetInputModes turns off the input modes a program may have left on the terminal,
+// mouse reporting (including button and any event tracking and SGR encoding),
+// bracketed paste, focus reporting and application cursor keys, in a single write.
+// Left on, the shell gets escape sequences for mouse moves and pastes after the program
+// is gone. Interactive programs should defer it, it's the input side of ResetTerminal.
+func ResetInputModes(w io.Writer) error {
+	_, err := io.WriteString(w, inputModesOff)
+	return err
+}
+
+// csiSubParams returns the parameters of the CSI sequence seq split into
+// their ':' separated sub-parameters. Empty ones are returned as 0.
+func csiSubParams(seq []byte) [][]int {
+	body := strings.TrimRight(string(seq[2:len(seq)-1]), " !\"#$%&'()*+,-./")
+	if body == "" {
+		return nil
+	}
+	var params [][]int
+	for _, p := range strings.Split(body, ";") {
+		var sub []int
+		for _, sp := range strings.Split(p, ":") {
+			n, _ := strconv.Atoi(sp)
+			sub = append(sub, n)
+		}
+		params = append(params, sub)
+	}
+	return params
+}
+
+// Keys for the kitty protocol key codes that aren't characters.
+var kittyKeys = map[int]KeyCode{
+	9:   KeyTab,
+	13:  KeyEnter,
+	27:  KeyEsc,
+	127: KeyBackspace,
+}
+
+// kittyKey decodes a kitty keyboard protocol "\033[code;modifiers:event;textu" key.
+func kittyKey(params [][]int) Key {
+	if len(params) == 0 {
+		return Key{Code: KeyUnknown}
+	}
+	code := params[0][0]
+	k := Key{Code: KeyRune, Rune: rune(code)}
+	switch kc, ok := kittyKeys[code]; {
+	case ok:
+		k = Key{Code: kc}
+	case code >= 57344 && code <= 63743, !utf8.ValidRune(rune(code)):
+		// Private use area, the functional keys without a KeyCode.
+		k = Key{Code: KeyUnknown}
+	}
+	if len(params) > 1 {
+		if mods := params[1][0]; mods > 1 {
+			k.setMods(mods)
+		}
+		if len(params[1]) > 1 {
+			k.Event = kittyEvent(params[1][1])
+		}
+	}
+	return k
+}
+
+// setMods adds the xterm / kitty modifier parameter mods to k, the lock states
+// to Locks and the rest to Mod.
+func (k *Key) setMods(mods int) {
+	m := KeyMod(mods - 1)
+	k.Mod |= m &^ modLocks
+	k.Locks |= m & modLocks
+}
+
+// kittyEvent returns the KeyEvent for the kitty protocol event type.
+func kittyEvent(typ int) KeyEvent {
+	switch typ {
+	case 2:
+		return KeyRepeat
+	case 3:
+		return KeyRelease
+	}
+	return KeyPress
+}
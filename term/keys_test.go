@@ -0,0 +1,351 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadKey tests decoding key presses.
+func TestReadKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Key
+	}{
+		{"a", Key{Code: KeyRune, Rune: 'a'}},
+		{"Å", Key{Code: KeyRune, Rune: 'Å'}},
+		{"日", Key{Code: KeyRune, Rune: '日'}},
+		{"😀", Key{Code: KeyRune, Rune: '😀'}},
+		{"\r", Key{Code: KeyEnter}},
+		{"\n", Key{Code: KeyEnter}},
+		{"\t", Key{Code: KeyTab}},
+		{"\x7f", Key{Code: KeyBackspace}},
+		{"\x08", Key{Code: KeyBackspace}},
+		{"\x03", Key{Code: KeyRune, Rune: 'c', Mod: ModCtrl}},
+		{"\x1c", Key{Code: KeyRune, Rune: '\\', Mod: ModCtrl}},
+		{"\x1b", Key{Code: KeyEsc}},
+		{"\x1b[A", Key{Code: KeyUp}},
+		{"\x1b[B", Key{Code: KeyDown}},
+		{"\x1b[C", Key{Code: KeyRight}},
+		{"\x1b[D", Key{Code: KeyLeft}},
+		{"\x1bOA", Key{Code: KeyUp}},
+		{"\x1bOB", Key{Code: KeyDown}},
+		{"\x1bOC", Key{Code: KeyRight}},
+		{"\x1bOD", Key{Code: KeyLeft}},
+		{"\x1bOp", Key{Code: KeyKP0}},
+		{"\x1bOy", Key{Code: KeyKP9}},
+		{"\x1bOM", Key{Code: KeyKPEnter}},
+		{"\x1bOn", Key{Code: KeyKPDecimal}},
+		{"\x1bOk", Key{Code: KeyKPPlus}},
+		{"\x1bOo", Key{Code: KeyKPDivide}},
+		{"\x1bOX", Key{Code: KeyKPEqual}},
+		{"\x1b[H", Key{Code: KeyHome}},
+		{"\x1bOF", Key{Code: KeyEnd}},
+		{"\x1b[1~", Key{Code: KeyHome}},
+		{"\x1b[3~", Key{Code: KeyDelete}},
+		{"\x1b[6~", Key{Code: KeyPgDown}},
+		{"\x1bOP", Key{Code: KeyF1}},
+		{"\x1b[24~", Key{Code: KeyF12}},
+		{"\x1b[1;5C", Key{Code: KeyRight, Mod: ModCtrl}},
+		{"\x1b[1;2A", Key{Code: KeyUp, Mod: ModShift}},
+		{"\x1b[3;3~", Key{Code: KeyDelete, Mod: ModAlt}},
+		{"\x1b[Z", Key{Code: KeyTab, Mod: ModShift}},
+		{"\x1bx", Key{Code: KeyRune, Rune: 'x', Mod: ModAlt}},
+		{"\x1b\x7f", Key{Code: KeyBackspace, Mod: ModAlt}},
+		{"\x1b[I", Key{Code: KeyFocusIn}},
+		{"\x1b[O", Key{Code: KeyFocusOut}},
+		{"\x1b[200~", Key{Code: KeyPasteStart}},
+		{"\x1b[201~", Key{Code: KeyPasteEnd}},
+		{"\x1b[97u", Key{Code: KeyRune, Rune: 'a'}},
+		{"\x1b[99;5u", Key{Code: KeyRune, Rune: 'c', Mod: ModCtrl}},
+		{"\x1b[27u", Key{Code: KeyEsc}},
+		{"\x1b[13;2u", Key{Code: KeyEnter, Mod: ModShift}},
+		{"\x1b[9;5u", Key{Code: KeyTab, Mod: ModCtrl}},
+		{"\x1b[127;3u", Key{Code: KeyBackspace, Mod: ModAlt}},
+		{"\x1b[97;9u", Key{Code: KeyRune, Rune: 'a', Mod: ModSuper}},
+		{"\x1b[97;65u", Key{Code: KeyRune, Rune: 'a', Locks: ModCapsLock}},
+		{"\x1b[99;197u", Key{Code: KeyRune, Rune: 'c', Mod: ModCtrl, Locks: ModCapsLock | ModNumLock}},
+		{"\x1b[1;129A", Key{Code: KeyUp, Locks: ModNumLock}},
+		{"\x1b[97;1:2u", Key{Code: KeyRune, Rune: 'a', Event: KeyRepeat}},
+		{"\x1b[97;6:3u", Key{Code: KeyRune, Rune: 'a', Mod: ModShift | ModCtrl, Event: KeyRelease}},
+		{"\x1b[97:65;2;65u", Key{Code: KeyRune, Rune: 'a', Mod: ModShift}},
+		{"\x1b[57399u", Key{Code: KeyUnknown}},
+		{"\x1b[1;1:3A", Key{Code: KeyUp, Event: KeyRelease}},
+		{"\x1b[3;5:1~", Key{Code: KeyDelete, Mod: ModCtrl}},
+		{"\x1b[?1u", Key{Code: KeyUnknown}},
+		{"\x1b[99x", Key{Code: KeyUnknown}},
+	}
+	for _, tst := range tests {
+		kr := NewKeyReader(strings.NewReader(tst.in))
+		got, err := kr.ReadKey()
+		if err != nil {
+			t.Errorf("ReadKey(%q) failed: %v", tst.in, err)
+			continue
+		}
+		if got != tst.want {
+			t.Errorf("ReadKey(%q) got: %+v want: %+v", tst.in, got, tst.want)
+		}
+	}
+	kr := NewKeyReader(strings.NewReader("ab\x1b[A"))
+	for _, want := range []Key{{Code: KeyRune, Rune: 'a'}, {Code: KeyRune, Rune: 'b'}, {Code: KeyUp}} {
+		if got, err := kr.ReadKey(); got != want || err != nil {
+			t.Errorf("ReadKey got: %+v, %v want: %+v, <nil>", got, err, want)
+		}
+	}
+	if _, err := kr.ReadKey(); err != io.EOF {
+		t.Errorf("ReadKey at end got: %v want: %v", err, io.EOF)
+	}
+}
+
+// TestReadKeyRaw tests the bytes read are returned with the keys.
+func TestReadKeyRaw(t *testing.T) {
+	in := "a\x1b[1;5A日\x1b[?99x\x03\x1b[200~"
+	kr := NewKeyReader(strings.NewReader(in))
+	for _, want := range []struct {
+		key Key
+		raw string
+	}{
+		{Key{Code: KeyRune, Rune: 'a'}, "a"},
+		{Key{Code: KeyUp, Mod: ModCtrl}, "\x1b[1;5A"},
+		{Key{Code: KeyRune, Rune: '日'}, "日"},
+		{Key{Code: KeyUnknown}, "\x1b[?99x"},
+		{Key{Code: KeyRune, Rune: 'c', Mod: ModCtrl}, "\x03"},
+		{Key{Code: KeyPasteStart}, "\x1b[200~"},
+	} {
+		k, raw, err := kr.ReadKeyRaw()
+		if err != nil || k != want.key || string(raw) != want.raw {
+			t.Errorf("ReadKeyRaw got: %+v, %q, %v want: %+v, %q, <nil>", k, raw, err, want.key, want.raw)
+		}
+	}
+	if _, raw, err := kr.ReadKeyRaw(); err != io.EOF || raw != nil {
+		t.Errorf("ReadKeyRaw at the end got: %q, %v want: nil, %v", raw, err, io.EOF)
+	}
+}
+
+// TestSetApplicationCursorKeys tests the application cursor keys sequences.
+func TestSetApplicationCursorKeys(t *testing.T) {
+	var out bytes.Buffer
+	SetApplicationCursorKeys(&out, true)
+	SetApplicationCursorKeys(&out, false)
+	if want := "\x1b[?1h\x1b[?1l"; out.String() != want {
+		t.Errorf("SetApplicationCursorKeys got: %q want: %q", out.String(), want)
+	}
+}
+
+// TestSetKeypadApplicationMode tests the keypad mode sequences.
+func TestSetKeypadApplicationMode(t *testing.T) {
+	var out bytes.Buffer
+	SetKeypadApplicationMode(&out, true)
+	SetKeypadApplicationMode(&out, false)
+	if want := "\x1b=\x1b>"; out.String() != want {
+		t.Errorf("SetKeypadApplicationMode got: %q want: %q", out.String(), want)
+	}
+}
+
+// TestFocusReporting tests the focus reporting sequences.
+func TestFocusReporting(t *testing.T) {
+	var out bytes.Buffer
+	EnableFocusReporting(&out)
+	DisableFocusReporting(&out)
+	if want := "\x1b[?1004h\x1b[?1004l"; out.String() != want {
+		t.Errorf("Enable/DisableFocusReporting got: %q want: %q", out.String(), want)
+	}
+}
+
+// TestReadPaste tests reading bracketed paste text.
+func TestReadPaste(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"\x1b[201~", ""},
+		{"hello\x1b[201~", "hello"},
+		{"one\rtwo\r\nthree\n\x1b[201~", "one\ntwo\nthree\n"},
+		{"\x1b[A\x1b[20~x\x1b[201~", "\x1b[A\x1b[20~x"},
+	}
+	for _, tst := range tests {
+		kr := NewKeyReader(strings.NewReader(tst.in + "z"))
+		got, err := kr.ReadPaste()
+		if err != nil || got != tst.want {
+			t.Errorf("ReadPaste(%q) got: %q, %v want: %q, <nil>", tst.in, got, err, tst.want)
+		}
+		if k, err := kr.ReadKey(); k.Rune != 'z' || err != nil {
+			t.Errorf("ReadKey after ReadPaste(%q) got: %+v, %v want: z", tst.in, k, err)
+		}
+	}
+	kr := NewKeyReader(strings.NewReader("cut short\x1b[20"))
+	if _, err := kr.ReadPaste(); err != io.EOF {
+		t.Errorf("ReadPaste without end got: %v want: %v", err, io.EOF)
+	}
+}
+
+// TestReadPastes tests a multi-line paste reads as one KeyPaste.
+func TestReadPastes(t *testing.T) {
+	paste := "\x1b[200~line one\r\nline two\n\x1b[Athree\x1b[201~"
+	kr := NewKeyReader(strings.NewReader("a" + paste + "b" + paste))
+	kr.ReadPastes = true
+	for _, want := range []Key{
+		{Code: KeyRune, Rune: 'a'},
+		{Code: KeyPaste, Text: "line one\nline two\n\x1b[Athree"},
+		{Code: KeyRune, Rune: 'b'},
+	} {
+		if k, err := kr.ReadKey(); k != want || err != nil {
+			t.Errorf("ReadKey got: %+v, %v want: %+v, <nil>", k, err, want)
+		}
+	}
+	if k, raw, err := kr.ReadKeyRaw(); k.Code != KeyPaste || string(raw) != paste || err != nil {
+		t.Errorf("ReadKeyRaw got: %+v, %q, %v want: KeyPaste, %q, <nil>", k, raw, err, paste)
+	}
+	kr = NewKeyReader(strings.NewReader("\x1b[200~cut short"))
+	kr.ReadPastes = true
+	if _, err := kr.ReadKey(); err != io.EOF {
+		t.Errorf("ReadKey of a paste without end got: %v want: %v", err, io.EOF)
+	}
+}
+
+// TestMaxPaste tests limiting and streaming large pastes.
+func TestMaxPaste(t *testing.T) {
+	kr := NewKeyReader(strings.NewReader("\x1b[200~0123\x1b[20x789\x1b[201~z\x1b[200~abc\x1b[201~"))
+	kr.ReadPastes, kr.MaxPaste = true, 5
+	want := Key{Code: KeyPaste, Text: "0123\x1b", Truncated: true}
+	if k, raw, err := kr.ReadKeyRaw(); k != want || string(raw) != "\x1b[200~0123\x1b\x1b[201~" || err != nil {
+		t.Errorf("ReadKeyRaw of a long paste got: %+v, %q, %v want: %+v", k, raw, err, want)
+	}
+	if k, err := kr.ReadKey(); k.Rune != 'z' || err != nil {
+		t.Errorf("ReadKey after a long paste got: %+v, %v want: z", k, err)
+	}
+	if k, err := kr.ReadKey(); k != (Key{Code: KeyPaste, Text: "abc"}) || err != nil {
+		t.Errorf("ReadKey of a short paste got: %+v, %v want: abc", k, err)
+	}
+
+	big := strings.Repeat("0123456789\r\n", 1000)
+	kr = NewKeyReader(strings.NewReader("\x1b[200~" + big + "\x1b[201~"))
+	kr.ReadPastes = true
+	var got strings.Builder
+	kr.OnPasteChunk = func(chunk []byte) {
+		if len(chunk) > pasteChunkSize {
+			t.Errorf("OnPasteChunk chunk got: %d bytes want: at most %d", len(chunk), pasteChunkSize)
+		}
+		got.Write(chunk)
+	}
+	if k, err := kr.ReadKey(); k != (Key{Code: KeyPaste}) || err != nil {
+		t.Errorf("ReadKey of a streamed paste got: %+v, %v want: %+v", k, err, Key{Code: KeyPaste})
+	}
+	if got.String() != big {
+		t.Errorf("OnPasteChunk got: %d bytes want: %d", got.Len(), len(big))
+	}
+}
+
+// countReader counts the Read calls.
+type countReader struct {
+	io.Reader
+	reads int
+}
+
+// Read implements the io.Reader interface.
+func (cr *countReader) Read(b []byte) (int, error) {
+	cr.reads++
+	return cr.Reader.Read(b)
+}
+
+// TestPasteBlocks tests pastes being read in blocks without losing the keys after them.
+func TestPasteBlocks(t *testing.T) {
+	big := strings.Repeat("x", 10*pasteChunkSize)
+	cr := &countReader{Reader: strings.NewReader("\x1b[200~" + big + "\x1b[201~ab\x1b[A")}
+	kr := NewKeyReader(cr)
+	kr.ReadPastes = true
+	if k, err := kr.ReadKey(); k.Code != KeyPaste || k.Text != big || err != nil {
+		t.Errorf("ReadKey of a big paste got: %v, %d bytes, %v want: KeyPaste, %d bytes", k.Code, len(k.Text), err, len(big))
+	}
+	if cr.reads > 20 {
+		t.Errorf("ReadKey of a big paste read %d times want: at most 20", cr.reads)
+	}
+	keys, err := kr.Drain()
+	want := []Key{{Code: KeyRune, Rune: 'a'}, {Code: KeyRune, Rune: 'b'}, {Code: KeyUp}}
+	if !reflect.DeepEqual(keys, want) || err != nil {
+		t.Errorf("Drain after a paste got: %+v, %v want: %+v, <nil>", keys, err, want)
+	}
+}
+
+// TestKittyKeyboard tests the kitty keyboard protocol sequences.
+func TestKittyKeyboard(t *testing.T) {
+	var out bytes.Buffer
+	EnableKittyKeyboard(&out, KittyDisambiguate|KittyReportEvents)
+	DisableKittyKeyboard(&out)
+	if want := "\x1b[>3u\x1b[<u"; out.String() != want {
+		t.Errorf("Enable/DisableKittyKeyboard got: %q want: %q", out.String(), want)
+	}
+}
+
+// TestBracketedPaste tests the bracketed paste sequences.
+func TestBracketedPaste(t *testing.T) {
+	var out bytes.Buffer
+	EnableBracketedPaste(&out)
+	DisableBracketedPaste(&out)
+	if want := "\x1b[?2004h\x1b[?2004l"; out.String() != want {
+		t.Errorf("Enable/DisableBracketedPaste got: %q want: %q", out.String(), want)
+	}
+}
+
+// TestResetInputModes tests all the input modes are turned off in one write.
+func TestResetInputModes(t *testing.T) {
+	var out countWriter
+	if err := ResetInputModes(&out); err != nil {
+		t.Fatalf("ResetInputModes failed: %v", err)
+	}
+	if out.n != len(inputModesOff) {
+		t.Errorf("ResetInputModes wrote: %d bytes want: %d", out.n, len(inputModesOff))
+	}
+	for _, mode := range []string{"1000", "1006", "2004", "1004", "1"} {
+		if !strings.Contains(inputModesOff, CSI+"?"+mode+"l") {
+			t.Errorf("ResetInputModes got: %q want: mode %s turned off", inputModesOff, mode)
+		}
+	}
+}
+
+// TestDrain tests reading all the keys waiting.
+func TestDrain(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	kr := NewKeyReader(tty.Slave)
+	if keys, err := kr.Drain(); len(keys) != 0 || err != nil {
+		t.Errorf("Drain with nothing typed got: %+v, %v want: [], <nil>", keys, err)
+	}
+	tty.Master.Write([]byte("ab\x1b[A\x03"))
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		if ok, _ := HasInput(tty.Slave); ok {
+			break
+		}
+	}
+	want := []Key{
+		{Code: KeyRune, Rune: 'a'},
+		{Code: KeyRune, Rune: 'b'},
+		{Code: KeyUp},
+		{Code: KeyRune, Rune: 'c', Mod: ModCtrl},
+	}
+	keys, err := kr.Drain()
+	if !reflect.DeepEqual(keys, want) || err != nil {
+		t.Errorf("Drain got: %+v, %v want: %+v, <nil>", keys, err, want)
+	}
+	start := time.Now()
+	if keys, err := kr.Drain(); len(keys) != 0 || err != nil {
+		t.Errorf("Drain after draining got: %+v, %v want: [], <nil>", keys, err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("Drain with nothing waiting took: %v want: no waiting", d)
+	}
+
+	kr = NewKeyReader(strings.NewReader("x\x1b[200~cut short"))
+	kr.ReadPastes = true
+	keys, err = kr.Drain()
+	if !reflect.DeepEqual(keys, []Key{{Code: KeyRune, Rune: 'x'}}) || err != io.EOF {
+		t.Errorf("Drain of a paste cut short got: %+v, %v want: [x], %v", keys, err, io.EOF)
+	}
+}
@@ -0,0 +1,541 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// copyBufSize is the buffer size Proxy copies with by default.
+// PTYs hand over at most 4KB per read, a bigger buffer keeps the slower in side going.
+const copyBufSize = 32 * 1024
+
+// pauseBufSize is the most Master output Pause buffers, older output is dropped.
+const pauseBufSize = 1 << 20
+
+// ProxyOption changes how Proxy copies, see ProxyBufferSizes.
+type ProxyOption func(*proxyConfig)
+
+// proxyConfig are the Proxy settings set by the ProxyOptions.
+type proxyConfig struct {
+	inSize, outSize int
+}
+
+// ProxyBufferSizes sets the sizes of the buffers Proxy copies with, in for the
+// input copied to the Master and out for the Master output, 32KB each by default.
+// A size <= 0 keeps the default.
+//
+// Reads return what's there, up to the buffer size, so a big buffer does not hold
+// back keystrokes, it caps how much a single write carries. Typed input is fine with
+// 4KB, bulk transfers in either direction gain from 32KB and up when the other side
+// is slow to write to.
+func ProxyBufferSizes(in, out int) ProxyOption {
+	return func(c *proxyConfig) {
+		if in > 0 {
+			c.inSize = in
+		}
+		if out > 0 {
+			c.outSize = out
+		}
+	}
+}
+
+// newProxyConfig returns the defaults changed by opts.
+func newProxyConfig(opts []ProxyOption) proxyConfig {
+	c := proxyConfig{inSize: copyBufSize, outSize: copyBufSize}
+	for _, o := range opts {
+		o(&c)
+	}
+	return c
+}
+
+// TranscriptTimeFormat is the timestamp format used in the RecordTimed transcripts.
+const TranscriptTimeFormat = time.RFC3339Nano
+
+// Proxy copies everything from in to the PTY Master and everything coming
+// out of the Master to out. This is the main loop of things like script and ssh servers.
+//
+// Proxy returns when the Slave side has been closed, eg. the child process exited and
+// the Slave was closed in the parent, or when copying fails.
+// See ProxyContext for the details.
+//
+// The copying is done in 32KB blocks, see ProxyBufferSizes. The PTY itself is the
+// bottleneck, expect a few hundred MB/s of output on current hardware, see BenchmarkProxy.
+func (p *PTY) Proxy(in io.Reader, out io.Writer, opts ...ProxyOption) error {
+	return p.ProxyContext(context.Background(), in, out, opts...)
+}
+
+// ProxyContext is Proxy that also returns when ctx is cancelled, eg. on a session timeout or
+// server shutdown. The error returned is then ctx.Err().
+//
+// Reaching the end of in does not stop the proxying, output from the Master is copied
+// until reading it returns EOF or EIO (all Slave fds closed) which returns a nil error.
+//
+// The Master read is unblocked on cancel using a read deadline. This relies on the Master
+// being in non-blocking mode, calling p.Master.Fd() puts it in blocking mode and leaves the
+// output copying running in the background until the next read from the Master returns.
+// The copying from in can't be interrupted, it keeps going in the background until
+// reading from in returns.
+//
+// The output to out can be paused and resumed, see Pause.
+func (p *PTY) ProxyContext(ctx context.Context, in io.Reader, out io.Writer, opts ...ProxyOption) error {
+	return p.proxy(ctx, in, p.forward(out), newProxyConfig(opts))
+}
+
+// readerOnly and writerOnly hide any WriteTo and ReadFrom methods, eg. those of
+// *os.File, so io.CopyBuffer copies with the buffer it's given.
+type readerOnly struct{ io.Reader }
+type writerOnly struct{ io.Writer }
+
+// proxy is ProxyContext without the pausing.
+func (p *PTY) proxy(ctx context.Context, in io.Reader, out io.Writer, c proxyConfig) error {
+	done := make(chan struct{})
+	p.mu.Lock()
+	p.proxied = done
+	p.mu.Unlock()
+	defer close(done)
+	master, sink := io.Writer(p.Master), io.MultiWriter(scrollbackWriter{p}, out)
+	if log := p.logger(); log != nil {
+		var inN, outN atomic.Int64
+		master, sink = &countingWriter{master, &inN}, &countingWriter{sink, &outN}
+		defer func() { log.Forwarded(inN.Load(), outN.Load()) }()
+	}
+	inErr, outErr := make(chan error, 1), make(chan error, 1)
+	go func() {
+		_, err := io.CopyBuffer(writerOnly{master}, readerOnly{in}, make([]byte, c.inSize))
+		inErr <- err
+	}()
+	go func() {
+		_, err := io.CopyBuffer(writerOnly{sink}, readerOnly{p.Master}, make([]byte, c.outSize))
+		outErr <- err
+	}()
+	// stop interrupts the output copying.
+	stop := func() {
+		if err := p.Master.SetReadDeadline(time.Now()); err != nil {
+			return
+		}
+		<-outErr
+		p.Master.SetReadDeadline(time.Time{})
+	}
+	for {
+		select {
+		case err := <-inErr:
+			if err != nil {
+				stop()
+				return err
+			}
+			// in is done, keep on copying the output.
+			inErr = nil
+		case err := <-outErr:
+			if errors.Is(err, syscall.EIO) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// RecordTimed is Proxy also writing a timestamped transcript of the Master output to log.
+// Every chunk read from the Master is written to log as a header line followed by the chunk:
+//
+//	<timestamp> <length>\n
+//	<length bytes of output>\n
+//
+// The timestamp is the wall-clock time, in UTC, the chunk was read formatted using
+// TranscriptTimeFormat and length the decimal number of output bytes in the chunk.
+// Failing to write the transcript stops the proxying.
+//
+// With RedactPatterns set the transcript is written a line at a time, matches in each
+// line replaced by "***", so a pattern split over two chunks still matches. A chunk
+// then holds the complete lines up to the last line ending read, with the timestamp
+// of when that was read. Lines longer than 32KB are written without waiting for their
+// end. out always gets the output as is.
+func (p *PTY) RecordTimed(in io.Reader, out io.Writer, log io.Writer, opts ...ProxyOption) error {
+	c := newProxyConfig(opts)
+	var tw io.Writer = &timedWriter{w: log, now: time.Now}
+	if len(p.RedactPatterns) == 0 {
+		return p.proxy(context.Background(), in, io.MultiWriter(p.forward(out), tw), c)
+	}
+	rw := &redactWriter{w: tw, patterns: p.RedactPatterns}
+	err := p.proxy(context.Background(), in, io.MultiWriter(p.forward(out), rw), c)
+	if ferr := rw.flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// Shutdown closes p once the child exited without losing the last of its output.
+// Closing the Master right away throws away what the child wrote that's not read yet.
+//
+// The Slave is closed, if it's still open in this process, and the rest of the output
+// is left to a running Proxy, or without one read here and written to the out of the
+// last Proxy (nowhere if there was none) and the scrollback, until the Master reads
+// EIO. That takes until every process having the Slave open is gone, Shutdown waits
+// for at most timeout before closing p anyway and returning ErrTimeout.
+// Errors draining and closing are returned together.
+func (p *PTY) Shutdown(timeout time.Duration) error {
+	var errs []error
+	p.mu.Lock()
+	slave, proxied, out := p.Slave, p.proxied, p.out
+	p.Slave = nil
+	p.mu.Unlock()
+	if slave != nil {
+		errs = append(errs, slave.Close())
+	}
+	select {
+	case <-proxied:
+		proxied = nil
+	default:
+	}
+	if proxied != nil {
+		select {
+		case <-proxied:
+		case <-time.After(timeout):
+			errs = append(errs, ErrTimeout)
+		}
+	} else {
+		errs = append(errs, p.drain(out, timeout))
+	}
+	errs = append(errs, p.Close())
+	return errors.Join(errs...)
+}
+
+// drain copies the Master output to out and the scrollback until EIO or timeout.
+func (p *PTY) drain(out io.Writer, timeout time.Duration) error {
+	if err := p.Master.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return p.closedErr(err)
+	}
+	var w io.Writer = scrollbackWriter{p}
+	if out != nil {
+		w = io.MultiWriter(w, pausableWriter{p})
+	}
+	_, err := io.CopyBuffer(w, readerOnly{p.Master}, make([]byte, copyBufSize))
+	switch {
+	case errors.Is(err, syscall.EIO):
+		return nil
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrTimeout
+	}
+	return err
+}
+
+// ForEachLine reads the Master output calling fn with every line, without the
+// line ending, until the Slave side is closed. For following the output of a child.
+//
+// A carriage return moves back to the start of the line, the text after it overwriting
+// what's there the way a terminal shows it, so eg. a progress meter redrawing itself
+// with "\r" shows up as its last state. Backspaces move one character back.
+// Escape sequences are not interpreted, they're part of the line as is.
+// A last line without a line ending is passed to fn as well.
+//
+// ForEachLine stops at the first error from fn and returns it. Reading EOF or EIO,
+// all Slave fds closed, returns nil.
+func (p *PTY) ForEachLine(fn func(line string) error) error {
+	r := bufio.NewReader(p.Master)
+	var line []rune
+	col := 0
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			if len(line) > 0 {
+				if ferr := fn(string(line)); ferr != nil {
+					return ferr
+				}
+			}
+			if err == io.EOF || errors.Is(err, syscall.EIO) {
+				return nil
+			}
+			return err
+		}
+		switch c {
+		case '\n':
+			if err := fn(string(line)); err != nil {
+				return err
+			}
+			line, col = line[:0], 0
+		case '\r':
+			col = 0
+		case '\b':
+			if col > 0 {
+				col--
+			}
+		default:
+			if col < len(line) {
+				line[col] = c
+			} else {
+				line = append(line, c)
+			}
+			col++
+		}
+	}
+}
+
+// Pause stops the running Proxy writing the Master output to out, eg. when the
+// client of a multiplexer detaches, without stopping the proxying itself. The child
+// keeps running and the Master is still read so the child never blocks on output.
+// The output while paused is buffered in memory, only the last pauseBufSize bytes
+// of it, to be written out by Resume, or with drop thrown away. Input keeps being
+// copied to the Master.
+// Only the output to out is paused, RecordTimed keeps the transcript going.
+func (p *PTY) Pause(drop bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused, p.drop = true, drop
+	p.pauses++
+}
+
+// Resume writes out the output buffered since Pause and goes back to copying
+// the Master output to out. Resume is a no-op when not paused.
+//
+// The output keeps being buffered until all of it is written so nothing newer
+// gets ahead of it, and a Pause while Resume is writing wins, leaving the output
+// paused with what was already buffered written out.
+func (p *PTY) Resume() error {
+	p.mu.Lock()
+	if !p.paused {
+		p.mu.Unlock()
+		return nil
+	}
+	out, pauses := p.out, p.pauses
+	p.mu.Unlock()
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	var err error
+	for {
+		p.mu.Lock()
+		if p.pauses != pauses {
+			p.mu.Unlock()
+			return err
+		}
+		pending := p.pending
+		if pending == nil || out == nil || err != nil {
+			p.paused, p.pending = false, nil
+			p.mu.Unlock()
+			return err
+		}
+		p.pending = nil
+		p.mu.Unlock()
+		// Not holding mu while writing, a slow out shouldn't block Pause.
+		_, err = out.Write(pending.Bytes())
+	}
+}
+
+// forward returns a writer writing to out unless the output is paused.
+func (p *PTY) forward(out io.Writer) io.Writer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.out = out
+	return pausableWriter{p}
+}
+
+// pausableWriter writes to the PTY out, or holds back the output while paused.
+type pausableWriter struct {
+	p *PTY
+}
+
+// Write implements the io.Writer interface.
+func (pw pausableWriter) Write(b []byte) (int, error) {
+	p := pw.p
+	p.mu.Lock()
+	if p.paused {
+		if !p.drop {
+			if p.pending == nil {
+				p.pending = newRing(pauseBufSize)
+			}
+			p.pending.Write(b)
+		}
+		p.mu.Unlock()
+		return len(b), nil
+	}
+	// Not holding mu while writing, a slow out shouldn't block Pause.
+	out := p.out
+	p.mu.Unlock()
+	p.outMu.Lock()
+	defer p.outMu.Unlock()
+	return out.Write(b)
+}
+
+// redactWriter writes complete lines to w with the matches of patterns replaced.
+type redactWriter struct {
+	w        io.Writer
+	patterns []*regexp.Regexp
+	buf      []byte // buf the output not written yet, an incomplete last line
+}
+
+// Write implements the io.Writer interface.
+func (rw *redactWriter) Write(b []byte) (int, error) {
+	rw.buf = append(rw.buf, b...)
+	end := bytes.LastIndexByte(rw.buf, '\n') + 1
+	if end == 0 {
+		if len(rw.buf) < copyBufSize {
+			return len(b), nil
+		}
+		end = len(rw.buf)
+	}
+	if err := rw.write(rw.buf[:end]); err != nil {
+		return 0, err
+	}
+	rw.buf = append(rw.buf[:0], rw.buf[end:]...)
+	return len(b), nil
+}
+
+// flush writes the incomplete last line.
+func (rw *redactWriter) flush() error {
+	if len(rw.buf) == 0 {
+		return nil
+	}
+	err := rw.write(rw.buf)
+	rw.buf = rw.buf[:0]
+	return err
+}
+
+// write redacts the lines in b and writes them as one chunk.
+func (rw *redactWriter) write(b []byte) error {
+	var out []byte
+	for len(b) > 0 {
+		line, rest, nl := bytes.Cut(b, newline)
+		for _, re := range rw.patterns {
+			line = re.ReplaceAll(line, redacted)
+		}
+		out = append(out, line...)
+		if nl {
+			out = append(out, '\n')
+		}
+		b = rest
+	}
+	_, err := rw.w.Write(out)
+	return err
+}
+
+var (
+	newline  = []byte("\n")
+	redacted = []byte("***") // redacted replaces the RedactPatterns matches
+)
+
+// countingWriter adds the bytes written to w to n, for the Logger.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Int64
+}
+
+// Write implements the io.Writer interface.
+func (cw *countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	cw.n.Add(int64(n))
+	return n, err
+}
+
+// timedWriter writes every Write to w prefixed with a timestamp header.
+type timedWriter struct {
+	w   io.Writer
+	now func() time.Time
+	buf []byte
+}
+
+// Write implements the io.Writer interface.
+func (tw *timedWriter) Write(b []byte) (int, error) {
+	tw.buf = tw.now().UTC().AppendFormat(tw.buf[:0], TranscriptTimeFormat)
+	tw.buf = append(tw.buf, ' ')
+	tw.buf = strconv.AppendInt(tw.buf, int64(len(b)), 10)
+	tw.buf = append(tw.buf, '\n')
+	tw.buf = append(tw.buf, b...)
+	tw.buf = append(tw.buf, '\n')
+	if _, err := tw.w.Write(tw.buf); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// SetScrollback makes Proxy keep the last n bytes of the Master output, for showing
+// a client reattaching after Pause what's on the screen. The output already kept is
+// cut down to the new size, n <= 0 stops keeping any.
+func (p *PTY) SetScrollback(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n <= 0 {
+		p.scroll = nil
+		return
+	}
+	r := newRing(n)
+	if p.scroll != nil {
+		r.Write(p.scroll.Bytes())
+	}
+	p.scroll = r
+}
+
+// Scrollback returns a copy of the Master output kept, see SetScrollback.
+func (p *PTY) Scrollback() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.scroll == nil {
+		return nil
+	}
+	return p.scroll.Bytes()
+}
+
+// scrollbackWriter writes the Master output to the PTY scrollback.
+type scrollbackWriter struct {
+	p *PTY
+}
+
+// Write implements the io.Writer interface.
+func (sw scrollbackWriter) Write(b []byte) (int, error) {
+	sw.p.mu.Lock()
+	defer sw.p.mu.Unlock()
+	if sw.p.scroll != nil {
+		sw.p.scroll.Write(b)
+	}
+	return len(b), nil
+}
+
+// ring keeps the last len(buf) bytes written to it.
+type ring struct {
+	buf  []byte
+	pos  int  // pos where the next byte goes
+	full bool // full buf has wrapped around, the oldest byte is at pos
+}
+
+// newRing returns a ring keeping the last n bytes.
+func newRing(n int) *ring {
+	return &ring{buf: make([]byte, n)}
+}
+
+// Write implements the io.Writer interface.
+func (r *ring) Write(b []byte) (int, error) {
+	n := len(b)
+	if n >= len(r.buf) {
+		copy(r.buf, b[n-len(r.buf):])
+		r.pos, r.full = 0, true
+		return n, nil
+	}
+	c := copy(r.buf[r.pos:], b)
+	copy(r.buf, b[c:])
+	if r.pos+n >= len(r.buf) {
+		r.full = true
+	}
+	r.pos = (r.pos + n) % len(r.buf)
+	return n, nil
+}
+
+// Bytes returns a copy of the bytes kept, oldest first.
+func (r *ring) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+	return append(append([]byte(nil), r.buf[r.pos:]...), r.buf[:r.pos]...)
+}
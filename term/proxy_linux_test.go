@@ -0,0 +1,702 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe to write and read from different goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(b []byte) (int, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.Write(b)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.buf.String()
+}
+
+// waitFor waits for sb to contain want.
+func (sb *syncBuffer) waitFor(want string, timeout time.Duration) bool {
+	for end := time.Now().Add(timeout); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+		if strings.Contains(sb.String(), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// rawPTY opens a new PTY with the Slave in raw mode.
+func rawPTY(t *testing.T) *PTY {
+	t.Helper()
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Raw()
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	return tty
+}
+
+// TestProxy tests proxying until the Slave is closed.
+func TestProxy(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.Proxy(strings.NewReader("input"), &out)
+	}()
+	in := make([]byte, 5)
+	if _, err := io.ReadFull(tty.Slave, in); err != nil {
+		t.Fatalf("Reading Slave failed: %v", err)
+	}
+	if string(in) != "input" {
+		t.Errorf("Slave got: %q want: %q", in, "input")
+	}
+	tty.Slave.Write([]byte("output"))
+	if !out.waitFor("output", time.Second) {
+		t.Errorf("Proxy out got: %q want: %q", out.String(), "output")
+	}
+	tty.Slave.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Proxy after Slave close got: %v want: <nil>", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Proxy did not return after Slave close")
+	}
+}
+
+// TestProxyContext tests that cancelling the context stops the proxying.
+func TestProxyContext(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var out syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.ProxyContext(ctx, pr, &out)
+	}()
+	tty.Slave.Write([]byte("hello"))
+	if !out.waitFor("hello", time.Second) {
+		t.Errorf("ProxyContext out got: %q want: %q", out.String(), "hello")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("ProxyContext after cancel got: %v want: %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ProxyContext did not return after cancel")
+	}
+	// The Master should still be usable.
+	tty.Slave.Write([]byte("again"))
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(tty.Master, b); err != nil || string(b) != "again" {
+		t.Errorf("Reading Master after ProxyContext got: %q, %v want: %q, <nil>", b, err, "again")
+	}
+}
+
+// maxWriter is a syncBuffer remembering its largest write.
+type maxWriter struct {
+	syncBuffer
+	max int
+}
+
+func (mw *maxWriter) Write(b []byte) (int, error) {
+	mw.mu.Lock()
+	mw.max = max(mw.max, len(b))
+	mw.mu.Unlock()
+	return mw.syncBuffer.Write(b)
+}
+
+// TestProxyBufferSizes tests Proxy copies all through small buffers.
+func TestProxyBufferSizes(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	var out maxWriter
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.Proxy(strings.NewReader("typed input"), &out, ProxyBufferSizes(2, 4))
+	}()
+	in := make([]byte, 11)
+	if _, err := io.ReadFull(tty.Slave, in); err != nil || string(in) != "typed input" {
+		t.Errorf("Slave got: %q, %v want: %q, <nil>", in, err, "typed input")
+	}
+	tty.Slave.Write([]byte("some output"))
+	if !out.waitFor("some output", time.Second) {
+		t.Errorf("Proxy out got: %q want: %q", out.String(), "some output")
+	}
+	out.mu.Lock()
+	if out.max > 4 {
+		t.Errorf("Proxy largest write got: %d want: at most 4", out.max)
+	}
+	out.mu.Unlock()
+	tty.Slave.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Proxy after Slave close got: %v want: <nil>", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Proxy did not return after Slave close")
+	}
+}
+
+// TestPause tests pausing and resuming the Proxy output.
+func TestPause(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var out, log syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.RecordTimed(pr, &out, &log)
+	}()
+	if err := tty.Resume(); err != nil {
+		t.Errorf("Resume when not paused failed: %v", err)
+	}
+	tty.Slave.Write([]byte("one"))
+	if !out.waitFor("one", time.Second) {
+		t.Fatalf("Proxy out got: %q want: %q", out.String(), "one")
+	}
+	// Buffered while paused, the transcript keeps going.
+	tty.Pause(false)
+	tty.Slave.Write([]byte("two"))
+	if !log.waitFor("two", time.Second) {
+		t.Fatalf("transcript got: %q want: %q", log.String(), "two")
+	}
+	if got := out.String(); got != "one" {
+		t.Errorf("Proxy out while paused got: %q want: %q", got, "one")
+	}
+	if err := tty.Resume(); err != nil {
+		t.Errorf("Resume failed: %v", err)
+	}
+	if got := out.String(); got != "onetwo" {
+		t.Errorf("Proxy out after Resume got: %q want: %q", got, "onetwo")
+	}
+	// Dropped while paused.
+	tty.Pause(true)
+	tty.Slave.Write([]byte("three"))
+	if !log.waitFor("three", time.Second) {
+		t.Fatalf("transcript got: %q want: %q", log.String(), "three")
+	}
+	if err := tty.Resume(); err != nil {
+		t.Errorf("Resume failed: %v", err)
+	}
+	tty.Slave.Write([]byte("four"))
+	if !out.waitFor("four", time.Second) {
+		t.Fatalf("Proxy out got: %q want: %q", out.String(), "four")
+	}
+	if got := out.String(); got != "onetwofour" {
+		t.Errorf("Proxy out after dropping got: %q want: %q", got, "onetwofour")
+	}
+	tty.Slave.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RecordTimed after Slave close got: %v want: <nil>", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RecordTimed did not return after Slave close")
+	}
+}
+
+// TestPauseLimit tests Pause keeping only the last pauseBufSize bytes.
+func TestPauseLimit(t *testing.T) {
+	var tty PTY
+	var out bytes.Buffer
+	w := tty.forward(&out)
+	tty.Pause(false)
+	w.Write(bytes.Repeat([]byte("a"), pauseBufSize))
+	w.Write([]byte("end"))
+	if err := tty.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if out.Len() != pauseBufSize || !bytes.HasSuffix(out.Bytes(), []byte("aend")) {
+		t.Errorf("Resume wrote %d bytes ending %q want: %d ending %q", out.Len(), out.Bytes()[out.Len()-4:], pauseBufSize, "aend")
+	}
+}
+
+// blockWriter blocks in Write until unblock is closed.
+type blockWriter struct {
+	unblock chan struct{}
+}
+
+// Write implements the io.Writer interface.
+func (bw blockWriter) Write(b []byte) (int, error) {
+	<-bw.unblock
+	return len(b), nil
+}
+
+// TestPauseSlowOut tests Pause not waiting on a Write to a slow out.
+func TestPauseSlowOut(t *testing.T) {
+	var tty PTY
+	bw := blockWriter{make(chan struct{})}
+	defer close(bw.unblock)
+	w := tty.forward(bw)
+	go w.Write([]byte("slow"))
+	paused := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tty.Pause(true)
+		close(paused)
+	}()
+	select {
+	case <-paused:
+	case <-time.After(time.Second):
+		t.Error("Pause blocked on the Write to out")
+	}
+}
+
+// gateWriter writes to w once gate is closed.
+type gateWriter struct {
+	w    io.Writer
+	gate chan struct{}
+}
+
+// Write implements the io.Writer interface.
+func (gw gateWriter) Write(b []byte) (int, error) {
+	<-gw.gate
+	return gw.w.Write(b)
+}
+
+// TestResumeSlowOut tests output and Pause not waiting on Resume writing to a slow out,
+// and the buffered output staying in order.
+func TestResumeSlowOut(t *testing.T) {
+	var tty PTY
+	var out syncBuffer
+	gate := make(chan struct{})
+	w := tty.forward(gateWriter{&out, gate})
+	tty.Pause(false)
+	w.Write([]byte("one"))
+	resumed := make(chan error, 1)
+	go func() {
+		resumed <- tty.Resume()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		w.Write([]byte("two"))
+		tty.Pause(false)
+		w.Write([]byte("three"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Write or Pause blocked on Resume writing to out")
+	}
+	close(gate)
+	if err := <-resumed; err != nil {
+		t.Errorf("Resume failed: %v", err)
+	}
+	if got := out.String(); got != "one" {
+		t.Errorf("Proxy out after Resume and Pause got: %q want: %q", got, "one")
+	}
+	if err := tty.Resume(); err != nil {
+		t.Errorf("Resume failed: %v", err)
+	}
+	w.Write([]byte("four"))
+	if got, want := out.String(), "onetwothreefour"; got != want {
+		t.Errorf("Proxy out after Resume got: %q want: %q", got, want)
+	}
+}
+
+// TestRing tests keeping the last bytes written.
+func TestRing(t *testing.T) {
+	tests := []struct {
+		writes []string
+		want   string
+	}{
+		{nil, ""},
+		{[]string{"ab"}, "ab"},
+		{[]string{"abcde"}, "abcde"},
+		{[]string{"abcdefg"}, "cdefg"},
+		{[]string{"abc", "de"}, "abcde"},
+		{[]string{"abc", "def"}, "bcdef"},
+		{[]string{"abcd", "e", "f", "g"}, "cdefg"},
+		{[]string{"ab", "cdefghijk", "l"}, "hijkl"},
+		{[]string{"abcde", "", "fghij", "k"}, "ghijk"},
+	}
+	for _, tst := range tests {
+		r := newRing(5)
+		for _, w := range tst.writes {
+			r.Write([]byte(w))
+		}
+		if got := string(r.Bytes()); got != tst.want {
+			t.Errorf("ring writes %q got: %q want: %q", tst.writes, got, tst.want)
+		}
+	}
+}
+
+// TestScrollback tests keeping the last Proxy output.
+func TestScrollback(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	if got := tty.Scrollback(); got != nil {
+		t.Errorf("Scrollback before SetScrollback got: %q want: <nil>", got)
+	}
+	tty.SetScrollback(8)
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var out syncBuffer
+	go tty.Proxy(pr, &out)
+	tty.Slave.Write([]byte("hello "))
+	tty.Pause(true)
+	tty.Slave.Write([]byte("world"))
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(5 * time.Millisecond) {
+		if string(tty.Scrollback()) == "lo world" {
+			break
+		}
+	}
+	if got := string(tty.Scrollback()); got != "lo world" {
+		t.Errorf("Scrollback got: %q want: %q", got, "lo world")
+	}
+	tty.SetScrollback(3)
+	if got := string(tty.Scrollback()); got != "rld" {
+		t.Errorf("Scrollback after shrinking got: %q want: %q", got, "rld")
+	}
+	tty.SetScrollback(0)
+	if got := tty.Scrollback(); got != nil {
+		t.Errorf("Scrollback turned off got: %q want: <nil>", got)
+	}
+}
+
+// TestForEachLine tests splitting the Master output into lines.
+func TestForEachLine(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"one\ntwo\n", []string{"one", "two"}},
+		{"one\r\n\r\ntwo", []string{"one", "", "two"}},
+		{"10%\r20%\r100%\n", []string{"100%"}},
+		{"abcdef\rxy\n", []string{"xycdef"}},
+		{"abc\b\bX\n", []string{"aXc"}},
+		{"\b\bab\n", []string{"ab"}},
+		{"日本\r語\n", []string{"語本"}},
+	}
+	for _, tst := range tests {
+		tty := rawPTY(t)
+		tty.Slave.Write([]byte(tst.in))
+		tty.Slave.Close()
+		var got []string
+		err := tty.ForEachLine(func(line string) error {
+			got = append(got, line)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("ForEachLine(%q) failed: %v", tst.in, err)
+		}
+		if !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("ForEachLine(%q) got: %q want: %q", tst.in, got, tst.want)
+		}
+		tty.Close()
+	}
+	tty := rawPTY(t)
+	defer tty.Close()
+	tty.Slave.Write([]byte("one\ntwo\nthree\n"))
+	stop := errors.New("stop")
+	var got []string
+	err := tty.ForEachLine(func(line string) error {
+		got = append(got, line)
+		if line == "two" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || !reflect.DeepEqual(got, []string{"one", "two"}) {
+		t.Errorf("ForEachLine stopping got: %q, %v want: %q, %v", got, err, []string{"one", "two"}, stop)
+	}
+}
+
+// TestTimedWriter tests the transcript format used by RecordTimed.
+func TestTimedWriter(t *testing.T) {
+	var log bytes.Buffer
+	ts := time.Date(2020, 9, 7, 3, 23, 37, 123456789, time.UTC)
+	tw := &timedWriter{w: &log, now: func() time.Time { return ts }}
+	for _, chunk := range []string{"hello\n", "", "wor\nld"} {
+		if n, err := tw.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Errorf("Write(%q) got: %d, %v want: %d, <nil>", chunk, n, err, len(chunk))
+		}
+	}
+	want := "2020-09-07T03:23:37.123456789Z 6\nhello\n\n" +
+		"2020-09-07T03:23:37.123456789Z 0\n\n" +
+		"2020-09-07T03:23:37.123456789Z 6\nwor\nld\n"
+	if log.String() != want {
+		t.Errorf("transcript got: %q want: %q", log.String(), want)
+	}
+}
+
+// TestRecordTimed tests recording a PTY session.
+func TestRecordTimed(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	var out, log syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.RecordTimed(strings.NewReader(""), &out, &log)
+	}()
+	tty.Slave.Write([]byte("recorded"))
+	if !out.waitFor("recorded", time.Second) {
+		t.Errorf("RecordTimed out got: %q want: %q", out.String(), "recorded")
+	}
+	tty.Slave.Close()
+	if err := <-done; err != nil {
+		t.Errorf("RecordTimed failed: %v", err)
+	}
+	hdr, chunk, _ := strings.Cut(log.String(), "\n")
+	ts, n, _ := strings.Cut(hdr, " ")
+	if _, err := time.Parse(TranscriptTimeFormat, ts); err != nil {
+		t.Errorf("transcript timestamp %q does not parse: %v", ts, err)
+	}
+	if n != "8" || chunk != "recorded\n" {
+		t.Errorf("transcript got: %q want: <timestamp> 8\\nrecorded\\n", log.String())
+	}
+}
+
+// TestRedactWriter tests the transcript lines are redacted, also matches split over writes.
+func TestRedactWriter(t *testing.T) {
+	var log strings.Builder
+	rw := &redactWriter{w: &log, patterns: []*regexp.Regexp{regexp.MustCompile(`secret\w*`), regexp.MustCompile(`\d{4}`)}}
+	for _, b := range []string{"pass: sec", "ret!\nno", "thing\n1", "23", "4"} {
+		if n, err := rw.Write([]byte(b)); n != len(b) || err != nil {
+			t.Errorf("Write(%q) got: %d, %v want: %d, <nil>", b, n, err, len(b))
+		}
+	}
+	if want := "pass: ***!\nnothing\n"; log.String() != want {
+		t.Errorf("redactWriter got: %q want: %q", log.String(), want)
+	}
+	if err := rw.flush(); err != nil {
+		t.Errorf("flush failed: %v", err)
+	}
+	if want := "pass: ***!\nnothing\n***"; log.String() != want {
+		t.Errorf("redactWriter after flush got: %q want: %q", log.String(), want)
+	}
+}
+
+// TestRecordTimedRedact tests RecordTimed leaves out the RedactPatterns.
+func TestRecordTimedRedact(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	tty.RedactPatterns = []*regexp.Regexp{regexp.MustCompile(`hunter\d`)}
+	var out, log syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.RecordTimed(strings.NewReader(""), &out, &log)
+	}()
+	tty.Slave.Write([]byte("pw hunter2\nbye"))
+	if !out.waitFor("hunter2\nbye", time.Second) {
+		t.Errorf("RecordTimed out got: %q want: %q", out.String(), "pw hunter2\nbye")
+	}
+	tty.Slave.Close()
+	if err := <-done; err != nil {
+		t.Errorf("RecordTimed failed: %v", err)
+	}
+	if got := log.String(); strings.Contains(got, "hunter2") || !strings.Contains(got, " 7\npw ***\n\n") || !strings.HasSuffix(got, " 3\nbye\n") {
+		t.Errorf("transcript got: %q want: pw ***\\n and bye chunks", got)
+	}
+}
+
+// TestShutdown tests the output left in the Master is not lost on Shutdown, with and
+// without a running Proxy, and the timeout while a child still has the Slave open.
+func TestShutdown(t *testing.T) {
+	tty := rawPTY(t)
+	tty.SetScrollback(64)
+	tty.Slave.Write([]byte("last words"))
+	if err := tty.Shutdown(time.Second); err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if got := string(tty.Scrollback()); got != "last words" {
+		t.Errorf("Shutdown drained got: %q want: %q", got, "last words")
+	}
+	if _, err := tty.Master.Write([]byte("x")); err == nil {
+		t.Error("Write after Shutdown got: <nil> want: error")
+	}
+
+	tty = rawPTY(t)
+	var out syncBuffer
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.Proxy(pr, &out)
+	}()
+	tty.Slave.Write([]byte("hi "))
+	if !out.waitFor("hi ", time.Second) {
+		t.Fatalf("Proxy out got: %q want: %q", out.String(), "hi ")
+	}
+	tty.Slave.Write([]byte("bye"))
+	if err := tty.Shutdown(time.Second); err != nil {
+		t.Errorf("Shutdown with Proxy failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Proxy failed: %v", err)
+	}
+	if out.String() != "hi bye" {
+		t.Errorf("Shutdown with Proxy out got: %q want: %q", out.String(), "hi bye")
+	}
+
+	tty = rawPTY(t)
+	cmd := exec.Command("sleep", "10")
+	cmd.Stdin = tty.Slave
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting sleep failed: %v", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+	if err := tty.Shutdown(20 * time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Errorf("Shutdown with the Slave open got: %v want: %v", err, ErrTimeout)
+	}
+}
+
+// eventLogger records the PTY events as strings.
+type eventLogger struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (el *eventLogger) add(ev string) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	el.events = append(el.events, ev)
+}
+
+func (el *eventLogger) Opened(name string) { el.add("opened " + name) }
+func (el *eventLogger) Resized(ws Winsize) {
+	el.add("resized " + strconv.Itoa(int(ws.WsRow)) + "x" + strconv.Itoa(int(ws.WsCol)))
+}
+func (el *eventLogger) Forwarded(in, out int64) {
+	el.add("forwarded " + strconv.FormatInt(in, 10) + " " + strconv.FormatInt(out, 10))
+}
+func (el *eventLogger) ChildExited(ps *os.ProcessState) {
+	el.add("exited " + strconv.Itoa(ps.ExitCode()))
+}
+func (el *eventLogger) Closed(err error) { el.add("closed") }
+
+// TestLogger tests the lifecycle events reported to the Logger.
+func TestLogger(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	name, err := tty.PTSName()
+	if err != nil {
+		t.Fatalf("PTSName failed: %v", err)
+	}
+	var el eventLogger
+	tty.SetLogger(&el)
+	if err := tty.Resize(Winsize{WsRow: 24, WsCol: 80}); err != nil {
+		t.Errorf("Resize failed: %v", err)
+	}
+	if ws, err := tty.WinsizeSlave(); err != nil || ws.WsRow != 24 || ws.WsCol != 80 {
+		t.Errorf("WinsizeSlave after Resize got: %+v, %v want: 24x80, <nil>", ws, err)
+	}
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- tty.Proxy(strings.NewReader("abc"), &out)
+	}()
+	in := make([]byte, 3)
+	if _, err := io.ReadFull(tty.Slave, in); err != nil {
+		t.Errorf("reading the Proxy input failed: %v", err)
+	}
+	tty.Slave.Write([]byte("hello"))
+	if !out.waitFor("hello", time.Second) {
+		t.Errorf("Proxy out got: %q want: %q", out.String(), "hello")
+	}
+	tty.Slave.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Proxy failed: %v", err)
+	}
+	cmd := exec.Command("false")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting false failed: %v", err)
+	}
+	tty.Wait(cmd.Process)
+	tty.Close()
+	tty.Close()
+	want := []string{"opened " + name, "resized 24x80", "forwarded 3 5", "exited 1", "closed"}
+	if !reflect.DeepEqual(el.events, want) {
+		t.Errorf("Logger events got: %q want: %q", el.events, want)
+	}
+}
+
+// countWriter counts the bytes written to it.
+type countWriter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (cw *countWriter) Write(b []byte) (int, error) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.n += len(b)
+	return len(b), nil
+}
+
+// BenchmarkProxy measures the Master to out throughput of Proxy.
+func BenchmarkProxy(b *testing.B) {
+	tty, err := OpenPTY()
+	if err != nil {
+		b.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	if err := tty.SetSlaveRaw(); err != nil {
+		b.Fatalf("SetSlaveRaw failed: %v", err)
+	}
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	var out countWriter
+	go tty.Proxy(pr, &out)
+	chunk := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+	b.SetBytes(int64(len(chunk)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tty.Slave.Write(chunk); err != nil {
+			b.Fatalf("Write failed: %v", err)
+		}
+	}
+	for want := b.N * len(chunk); ; time.Sleep(time.Millisecond) {
+		out.mu.Lock()
+		n := out.n
+		out.mu.Unlock()
+		if n >= want {
+			break
+		}
+	}
+}
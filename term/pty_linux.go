@@ -1,9 +1,15 @@
 package term
 
 import (
+	"errors"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
@@ -12,63 +18,318 @@ const (
 	TIOCSPTLCK = 0x40045431
 )
 
+const (
+	defaultPTMX   = "/dev/ptmx"
+	defaultPTSDir = "/dev/pts"
+)
+
 // PTSName return the name of the pty.
 func (p *PTY) PTSName() (string, error) {
 	n, err := p.PTSNumber()
 	if err != nil {
 		return "", err
 	}
-	return "/dev/pts/" + strconv.Itoa(int(n)), nil
+	dir := p.ptsDir
+	if dir == "" {
+		dir = defaultPTSDir
+	}
+	return dir + "/" + strconv.Itoa(int(n)), nil
+}
+
+// ListPTS returns the paths of the PTY slaves currently allocated in /dev/pts,
+// in numeric order. Everything not a pty number, eg. ptmx, is skipped.
+// Lets a multiplexer reconcile the sessions it knows of with the kernel's view.
+func ListPTS() ([]string, error) {
+	ents, err := os.ReadDir(defaultPTSDir)
+	if err != nil {
+		return nil, err
+	}
+	var nums []int
+	for _, e := range ents {
+		if n, err := strconv.Atoi(e.Name()); err == nil && n >= 0 {
+			nums = append(nums, n)
+		}
+	}
+	sort.Ints(nums)
+	pts := make([]string, len(nums))
+	for i, n := range nums {
+		pts[i] = defaultPTSDir + "/" + strconv.Itoa(n)
+	}
+	return pts, nil
+}
+
+// ptsDirFor returns the devpts directory belonging to the ptmx device at path.
+// A ptmx inside a devpts mount eg. /dev/pts/ptmx lives next to its slaves,
+// otherwise the slaves are expected in the pts directory next to it.
+func ptsDirFor(path string) string {
+	dir := filepath.Dir(path)
+	if filepath.Base(dir) == "pts" {
+		return dir
+	}
+	return filepath.Join(dir, "pts")
 }
 
 // PTSNumber return the pty number.
 func (p *PTY) PTSNumber() (uint, error) {
-	var ptyno uint
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(p.Master.Fd()), uintptr(TIOCGPTN), uintptr(unsafe.Pointer(&ptyno)))
-	if errno != 0 {
-		return 0, errno
+	var ptyno uint32
+	if err := ioctl(p.Master, TIOCGPTN, unsafe.Pointer(&ptyno)); err != nil {
+		return 0, p.closedErr(err)
 	}
-	return ptyno, nil
+	return uint(ptyno), nil
 }
 
 func (p *PTY) PTSUnlock() error {
 	// unlock pty slave
-	var unlock int // 0 => Unlock
-	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(p.Master.Fd()), uintptr(TIOCSPTLCK), uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+	var unlock int32 // 0 => Unlock
+	if err := ioctl(p.Master, TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
 		p.Master.Close()
+		return err
+	}
+	return nil
+}
+
+// WinsizeSlave reads the current window size of the Slave.
+// Lets a supervising process notice the child resizing its terminal.
+func (p *PTY) WinsizeSlave() (Winsize, error) {
+	var wz Winsize
+	if err := ioctl(p.Slave, syscall.TIOCGWINSZ, unsafe.Pointer(&wz)); err != nil {
+		return Winsize{}, p.closedErr(err)
+	}
+	return wz, nil
+}
+
+// SetSlaveRaw sets the Slave to Raw() mode, see the PTY description.
+// What's written to the Master reaches a program reading the Slave byte by byte,
+// and what it writes comes out of the Master as is.
+func (p *PTY) SetSlaveRaw() error {
+	t, err := Attr(p.Slave)
+	if err != nil {
+		return p.closedErr(err)
+	}
+	t.Raw()
+	return p.closedErr(t.Set(p.Slave))
+}
+
+// SetSlaveCooked sets the Slave to Cook() mode, see the PTY description.
+// What's written to the Master reaches a program reading the Slave a line at a time
+// and signal characters, eg. ^C, are turned into signals.
+func (p *PTY) SetSlaveCooked() error {
+	t, err := Attr(p.Slave)
+	if err != nil {
+		return p.closedErr(err)
+	}
+	t.Cook()
+	return p.closedErr(t.Set(p.Slave))
+}
+
+// WaitReadable waits up to timeout for output from the Slave to read on the Master,
+// without reading any of it. Returns false with no error on timeout.
+func (p *PTY) WaitReadable(timeout time.Duration) (bool, error) {
+	ok, err := pollIn(p.Master, timeout)
+	return ok, p.closedErr(err)
+}
+
+// TeeMaster returns a reader of the Master that also writes everything read to w,
+// eg. a transcript logger next to the live consumer, from a single read loop.
+// There can't be two independent readers of the Master, each would only see part
+// of the output.
+//
+// Only one goroutine should drive the returned reader, and nothing else should read
+// the Master while it's in use. Errors writing to w are returned by Read.
+func (p *PTY) TeeMaster(w io.Writer) io.Reader {
+	return io.TeeReader(p.Master, w)
+}
+
+// Logger gets told about the lifecycle events of a PTY, eg. for tracing the sessions
+// of a server, see SetLogger. The calls are made synchronously from the goroutine
+// causing the event and should return quickly.
+type Logger interface {
+	Opened(name string)              // Opened the PTY with the Slave name is being logged
+	Resized(ws Winsize)              // Resized the Slave window size was set with Resize
+	Forwarded(in, out int64)         // Forwarded bytes copied to and from the Master by a Proxy that returned
+	ChildExited(ps *os.ProcessState) // ChildExited a process waited for with Wait exited
+	Closed(err error)                // Closed the PTY was closed, err is what Close returned
+}
+
+// SetLogger makes p report its lifecycle events to l, nil stops the logging.
+// The PTY is already open so Opened is called right away by SetLogger.
+// Without a Logger nothing is counted or reported.
+func (p *PTY) SetLogger(l Logger) {
+	p.mu.Lock()
+	p.log = l
+	p.mu.Unlock()
+	if l != nil {
+		name, _ := p.PTSName()
+		l.Opened(name)
+	}
+}
+
+// logger returns the Logger set with SetLogger, nil when there's none.
+func (p *PTY) logger() Logger {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.log
+}
+
+// Resize sets the window size of the Slave, the child gets SIGWINCH.
+// It's set through the Master so it also works once the Slave is closed, see Shell.
+func (p *PTY) Resize(ws Winsize) error {
+	if err := ioctl(p.Master, syscall.TIOCSWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return p.closedErr(err)
+	}
+	if l := p.logger(); l != nil {
+		l.Resized(ws)
+	}
+	return nil
+}
+
+// Wait waits for the child proc running on the PTY to exit, see os.Process.Wait.
+func (p *PTY) Wait(proc *os.Process) (*os.ProcessState, error) {
+	ps, err := proc.Wait()
+	if l := p.logger(); l != nil && ps != nil {
+		l.ChildExited(ps)
+	}
+	return ps, err
+}
+
+// Signal sends sig to the foreground process group of the Slave, eg. SIGINT to
+// forward a ^C without relying on ISIG. The group is looked up with TIOCGPGRP.
+func (p *PTY) Signal(sig syscall.Signal) error {
+	var pgrp int32
+	if err := ioctl(p.Master, syscall.TIOCGPGRP, unsafe.Pointer(&pgrp)); err != nil {
+		return p.closedErr(err)
+	}
+	if pgrp <= 0 {
+		return errors.New("no foreground process group")
+	}
+	return syscall.Kill(-int(pgrp), sig)
+}
+
+// ioctl does the ioctl req with arg on f.
+// Unlike going through f.Fd() this leaves f in non-blocking mode so read deadlines
+// keep working on the Master.
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
 		return errno
 	}
 	return nil
 }
 
+// defaultShell is started by Shell when $SHELL is not set or not found.
+const defaultShell = "/bin/sh"
+
+// Shell opens a PTY of size ws and starts the user's shell on it, $SHELL or /bin/sh
+// when that's not set or can't be found. The shell is a session leader with the Slave
+// as its controlling terminal and stdin, stdout and stderr, like a login from ssh.
+// env is the environment of the shell, nil for the environment of this program.
+//
+// The Slave is closed in the parent and set to nil, so reading the Master returns EIO
+// once the shell and everything it started have closed the Slave, see Proxy.
+// Wait for the returned process with PTY.Wait and Close the PTY when done.
+func Shell(env []string, ws Winsize) (*PTY, *os.Process, error) {
+	shell, err := exec.LookPath(os.Getenv("SHELL"))
+	if err != nil {
+		shell = defaultShell
+	}
+	p, err := OpenPTY()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := p.Resize(ws); err != nil {
+		p.Close()
+		return nil, nil, err
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	proc, err := os.StartProcess(shell, []string{shell}, &os.ProcAttr{
+		Env:   env,
+		Files: []*os.File{p.Slave, p.Slave, p.Slave},
+		Sys:   &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0},
+	})
+	if err != nil {
+		p.Close()
+		return nil, nil, err
+	}
+	p.Slave.Close()
+	p.Slave = nil
+	return p, proc, nil
+}
+
 // OpenPTY Creates a new Master/Slave PTY pair.
 func OpenPTY() (*PTY, error) {
-	// Opening ptmx gives you the FD of a brand new PTY
-	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	return OpenPTYAt(defaultPTMX)
+}
+
+// OpenPTYAt Creates a new Master/Slave PTY pair using the ptmx device at ptmxPath.
+// Used for allocating from a specific devpts instance, eg. a container with a
+// private /dev/pts where ptmxPath would be /dev/pts/ptmx.
+// An empty ptmxPath defaults to /dev/ptmx.
+func OpenPTYAt(ptmxPath string) (*PTY, error) {
+	pty, slaveStr, err := openMaster(ptmxPath)
 	if err != nil {
 		return nil, err
 	}
-	pty := &PTY{Master: master}
 
-	err = pty.PTSUnlock()
+	// open pty slave
+	pty.Slave, err = os.OpenFile(slaveStr, os.O_RDWR|syscall.O_NOCTTY, 0)
 	if err != nil {
-		master.Close()
+		pty.Master.Close()
 		return nil, err
 	}
 
-	// get path of pts slave
-	slaveStr, err := pty.PTSName()
+	return pty, nil
+}
+
+// OpenPTYName Creates a new PTY returning its unlocked master and the path of
+// its slave, eg. /dev/pts/3, without opening the slave.
+// For handing the slave to another process that opens it itself, no slave FD is held here.
+// The caller owns the master and closes it when done.
+func OpenPTYName() (master *os.File, slaveName string, err error) {
+	pty, slaveName, err := openMaster(defaultPTMX)
 	if err != nil {
-		master.Close()
-		return nil, err
+		return nil, "", err
 	}
+	return pty.Master, slaveName, nil
+}
 
-	// open pty slave
-	pty.Slave, err = os.OpenFile(slaveStr, os.O_RDWR|syscall.O_NOCTTY, 0)
+// openMaster opens and unlocks a new master at ptmxPath returning it in a PTY with
+// no Slave, and the path of the slave.
+func openMaster(ptmxPath string) (*PTY, string, error) {
+	if ptmxPath == "" {
+		ptmxPath = defaultPTMX
+	}
+	// Opening ptmx gives you the FD of a brand new PTY
+	master, err := os.OpenFile(ptmxPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	pty := &PTY{Master: master}
+	if ptmxPath != defaultPTMX {
+		pty.ptsDir = ptsDirFor(ptmxPath)
+	}
+
+	err = pty.PTSUnlock()
 	if err != nil {
 		master.Close()
-		return nil, err
+		return nil, "", err
 	}
 
-	return pty, nil
+	// get path of pts slave
+	slaveStr, err := pty.PTSName()
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	return pty, slaveStr, nil
 }
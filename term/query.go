@@ -0,0 +1,227 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DefaultQueryTimeout is how long ProbeFeatures waits for each reply.
+// Local terminals answer within a few milliseconds, leave room for ssh.
+const DefaultQueryTimeout = 200 * time.Millisecond
+
+// QueryOption changes how Query waits for the reply, see QueryRetries.
+type QueryOption func(*queryConfig)
+
+// queryConfig are the Query settings set by the QueryOptions.
+type queryConfig struct {
+	retries int
+	step    time.Duration
+}
+
+// QueryRetries makes Query send the query again, up to n more times, when no reply
+// arrived in time, eg. over a slow ssh connection. The wait grows linearly, every
+// retry waits step longer than the one before. Without it the query is sent once.
+//
+// Replies to the earlier sends can still turn up late. What's waiting is thrown away
+// before every resend, and once a reply is in the ones still owed are waited for and
+// thrown away too, as long as the last wait, so none is left to be echoed once the
+// terminal is back out of raw mode.
+func QueryRetries(n int, step time.Duration) QueryOption {
+	return func(c *queryConfig) {
+		c.retries, c.step = n, step
+	}
+}
+
+// Query writes the query req, eg. "\033[c" for the primary device attributes, to the
+// terminal f and returns the escape sequence the terminal replies with, see ParseCSI.
+// Anything else arriving before the reply, eg. typeahead, is thrown away.
+// ErrTimeout is returned if no reply arrived within timeout, after any retries,
+// see QueryRetries.
+//
+// f is set to raw mode while waiting for the reply and its attributes restored after,
+// so the reply is not echoed and needs no Enter.
+func Query(f *os.File, req string, timeout time.Duration, opts ...QueryOption) ([]byte, error) {
+	var c queryConfig
+	for _, o := range opts {
+		o(&c)
+	}
+	var reply []byte
+	err := WithRaw(f, func() error {
+		owed := 0 // owed replies to the earlier sends not seen yet
+		for try := 0; ; try++ {
+			if try > 0 {
+				owed -= skipReplies(f, owed, 0)
+			}
+			wait := timeout + time.Duration(try)*c.step
+			var err error
+			reply, err = query(f, req, wait)
+			if err == nil && owed > 0 {
+				skipReplies(f, owed, wait)
+			}
+			if err != ErrTimeout || try >= c.retries {
+				return err
+			}
+			owed++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// query sends req and waits for the reply, f already in raw mode.
+// A reply cut off by the timeout is thrown away, what's left of it arriving later
+// is skipped as it does not start with ESC.
+func query(f *os.File, req string, timeout time.Duration) ([]byte, error) {
+	if _, err := f.WriteString(req); err != nil {
+		return nil, err
+	}
+	end := time.Now().Add(timeout)
+	for {
+		b, err := ReadByteTimeout(f, time.Until(end))
+		if err != nil {
+			return nil, err
+		}
+		if b != esc {
+			continue
+		}
+		return ReadEscapeSequence(f, time.Until(end))
+	}
+}
+
+// skipReplies reads and throws away up to n replies, escape sequences, arriving
+// within d, returning how many it threw away. A d of 0 only takes what's waiting.
+func skipReplies(f *os.File, n int, d time.Duration) int {
+	end := time.Now().Add(d)
+	skipped := 0
+	for skipped < n {
+		b, err := ReadByteTimeout(f, time.Until(end))
+		if err != nil {
+			return skipped
+		}
+		if b != esc {
+			continue
+		}
+		if _, err := ReadEscapeSequence(f, time.Until(end)); err != nil {
+			return skipped
+		}
+		skipped++
+	}
+	return skipped
+}
+
+// Features the terminal supports, see ProbeFeatures.
+type Features struct {
+	Responded      bool // Responded the terminal answered the device attributes query
+	Truecolor      bool // Truecolor 24-bit colors, see NewColorRGB
+	Mouse          bool // Mouse xterm mouse reporting
+	BracketedPaste bool // BracketedPaste see EnableBracketedPaste
+	Sixel          bool // Sixel sixel graphics
+}
+
+// ProbeFeatures finds out what the terminal f supports by asking it for its primary
+// device attributes (DA1, "\033[c") and, using DECRQM ("\033[?<mode>$p"), whether it knows
+// the xterm mouse (1000) and bracketed paste (2004) modes.
+// Truecolor is taken from the COLORTERM environment variable being "truecolor" or "24bit",
+// there's no reliable way of asking the terminal.
+//
+// Sixel is only reported from the DA1 reply. Terminals not answering DECRQM are guessed
+// to support mouse and bracketed paste when Capabilities of TERM has Mouse set, eg.
+// for xterm, screen, tmux or rxvt.
+//
+// When the terminal does not answer at all, eg. it's not a terminal emulator, only
+// Truecolor can be set. Every query waits DefaultQueryTimeout at most.
+func ProbeFeatures(f *os.File) (Features, error) {
+	feat := Features{
+		Truecolor: os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit",
+	}
+	reply, err := Query(f, CSI+"c", DefaultQueryTimeout)
+	if err == ErrTimeout {
+		return feat, nil
+	}
+	if err != nil {
+		return feat, err
+	}
+	da, err := ParseCSI(reply)
+	if err != nil || da.Private != '?' || da.Final != 'c' {
+		return feat, nil
+	}
+	feat.Responded = true
+	for _, p := range da.Params[min(1, len(da.Params)):] {
+		if p == 4 {
+			feat.Sixel = true
+		}
+	}
+	guess := Capabilities(os.Getenv("TERM")).Mouse
+	for _, m := range []struct {
+		mode string
+		to   *bool
+	}{
+		{"1000", &feat.Mouse},
+		{"2004", &feat.BracketedPaste},
+	} {
+		known, answered, err := queryMode(f, m.mode)
+		if err != nil {
+			return feat, err
+		}
+		*m.to = known || !answered && guess
+	}
+	return feat, nil
+}
+
+// queryMode asks the terminal f with DECRQM whether it knows the private mode.
+// answered is false when the terminal did not reply.
+func queryMode(f *os.File, mode string) (known, answered bool, err error) {
+	reply, err := Query(f, CSI+"?"+mode+"$p", DefaultQueryTimeout)
+	if err == ErrTimeout {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	// "\033[?<mode>;<status>$y", status 0 not recognized, 1 / 3 set, 2 / 4 reset.
+	r, err := ParseCSI(reply)
+	if err != nil || r.Final != 'y' || len(r.Params) != 2 {
+		return false, false, nil
+	}
+	return r.Params[1] != 0, true, nil
+}
+
+// ReportGeometry asks the terminal f for the size of its text area using XTWINOPS,
+// in characters ("\033[18t") and in pixels ("\033[14t"). A fallback for terminals
+// leaving the pixel fields of Winsize 0, eg. for working out the cell size for graphics.
+// Every query waits DefaultQueryTimeout at most, ErrTimeout is returned if the
+// terminal did not answer, see Query.
+func ReportGeometry(f *os.File) (cols, rows, wpx, hpx int, err error) {
+	if rows, cols, err = reportWindow(f, 18, 8); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if hpx, wpx, err = reportWindow(f, 14, 4); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	return cols, rows, wpx, hpx, nil
+}
+
+// reportWindow sends the XTWINOPS report op and returns the height and width of
+// the "\033[<code>;<height>;<width>t" reply.
+func reportWindow(f *os.File, op, code int) (height, width int, err error) {
+	reply, err := Query(f, CSI+strconv.Itoa(op)+"t", DefaultQueryTimeout)
+	if err != nil {
+		return 0, 0, err
+	}
+	r, err := ParseCSI(reply)
+	if err != nil {
+		return 0, 0, err
+	}
+	if r.Final != 't' || len(r.Params) != 3 || r.Params[0] != code {
+		return 0, 0, errors.New("unexpected XTWINOPS reply: " + strconv.Quote(string(reply)))
+	}
+	return r.Params[1], r.Params[2], nil
+}
@@ -0,0 +1,199 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTerminal answers the queries coming out of the Master with replies.
+// Queries with no reply are ignored.
+func fakeTerminal(tty *PTY, replies map[string]string) {
+	go func() {
+		var got string
+		b := make([]byte, 256)
+		for {
+			n, err := tty.Master.Read(b)
+			if err != nil {
+				return
+			}
+			got += string(b[:n])
+			for q, r := range replies {
+				if i := strings.Index(got, q); i >= 0 {
+					got = got[i+len(q):]
+					tty.Master.Write([]byte(r))
+				}
+			}
+		}
+	}()
+}
+
+// TestQuery tests querying the terminal.
+func TestQuery(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	before, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	fakeTerminal(tty, map[string]string{
+		"\033[6n": "typed\033[24;80R",
+		"\033[c":  "\033[?62;4c",
+	})
+	got, err := Query(tty.Slave, "\033[6n", time.Second)
+	if err != nil || string(got) != "\033[24;80R" {
+		t.Errorf("Query(DSR) got: %q, %v want: %q, <nil>", got, err, "\033[24;80R")
+	}
+	if got, err = Query(tty.Slave, "\033[c", time.Second); err != nil || string(got) != "\033[?62;4c" {
+		t.Errorf("Query(DA1) got: %q, %v want: %q, <nil>", got, err, "\033[?62;4c")
+	}
+	if _, err = Query(tty.Slave, "\033[>c", 20*time.Millisecond); err != ErrTimeout {
+		t.Errorf("Query without reply got: %v want: %v", err, ErrTimeout)
+	}
+	after, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if after != before {
+		t.Errorf("Query attributes not restored got: %v want: %v", after, before)
+	}
+}
+
+// TestQueryRetries tests the query is sent again when the first goes unanswered.
+func TestQueryRetries(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	// Answer the second query only, after some noise.
+	go func() {
+		var got string
+		b := make([]byte, 256)
+		for {
+			n, err := tty.Master.Read(b)
+			if err != nil {
+				return
+			}
+			got += string(b[:n])
+			if strings.Count(got, "\033[6n") == 2 {
+				tty.Master.Write([]byte("x\033[5;1R"))
+				return
+			}
+		}
+	}()
+	if _, err := Query(tty.Slave, "\033[6n", 20*time.Millisecond); err != ErrTimeout {
+		t.Errorf("Query without retries got: %v want: %v", err, ErrTimeout)
+	}
+	got, err := Query(tty.Slave, "\033[6n", 20*time.Millisecond, QueryRetries(2, 10*time.Millisecond))
+	if err != nil || string(got) != "\033[5;1R" {
+		t.Errorf("Query with retries got: %q, %v want: %q, <nil>", got, err, "\033[5;1R")
+	}
+	start := time.Now()
+	if _, err := Query(tty.Slave, "\033[6n", 10*time.Millisecond, QueryRetries(2, 10*time.Millisecond)); err != ErrTimeout {
+		t.Errorf("Query with retries unanswered got: %v want: %v", err, ErrTimeout)
+	}
+	if d := time.Since(start); d < 60*time.Millisecond {
+		t.Errorf("Query with 2 retries took: %v want: at least 60ms", d)
+	}
+}
+
+// TestQueryLateReply tests the late replies to earlier sends are not left unread.
+func TestQueryLateReply(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	// Answer every query, but slower than the first wait.
+	go func() {
+		b := make([]byte, 256)
+		for {
+			n, err := tty.Master.Read(b)
+			if err != nil {
+				return
+			}
+			for range strings.Count(string(b[:n]), "\033[6n") {
+				time.AfterFunc(40*time.Millisecond, func() {
+					tty.Master.Write([]byte("\033[5;1R"))
+				})
+			}
+		}
+	}()
+	got, err := Query(tty.Slave, "\033[6n", 20*time.Millisecond, QueryRetries(2, 40*time.Millisecond))
+	if err != nil || string(got) != "\033[5;1R" {
+		t.Errorf("Query with a late reply got: %q, %v want: %q, <nil>", got, err, "\033[5;1R")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ok, err := HasInput(tty.Slave); ok || err != nil {
+		t.Errorf("HasInput after Query got: %t, %v want: false, <nil>", ok, err)
+	}
+}
+
+// TestReportGeometry tests reading the text area size in characters and pixels.
+func TestReportGeometry(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	fakeTerminal(tty, map[string]string{
+		"\033[18t": "\033[8;24;80t",
+		"\033[14t": "\033[4;480;720t",
+	})
+	cols, rows, wpx, hpx, err := ReportGeometry(tty.Slave)
+	if err != nil || cols != 80 || rows != 24 || wpx != 720 || hpx != 480 {
+		t.Errorf("ReportGeometry got: %d, %d, %d, %d, %v want: 80, 24, 720, 480, <nil>", cols, rows, wpx, hpx, err)
+	}
+	bad, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer bad.Close()
+	fakeTerminal(bad, map[string]string{"\033[18t": "\033[1;2R"})
+	if _, _, _, _, err := ReportGeometry(bad.Slave); err == nil {
+		t.Errorf("ReportGeometry with a bad reply got: <nil> want: error")
+	}
+}
+
+// TestProbeFeatures tests working out the terminal features from the replies.
+func TestProbeFeatures(t *testing.T) {
+	tests := []struct {
+		name      string
+		term      string
+		colorterm string
+		replies   map[string]string
+		want      Features
+	}{
+		{"silent", "xterm", "truecolor", nil, Features{Truecolor: true}},
+		{"vt100", "vt100", "", map[string]string{"\033[c": "\033[?1;2c"}, Features{Responded: true}},
+		{"guessed", "xterm-256color", "", map[string]string{"\033[c": "\033[?62;4;22c"},
+			Features{Responded: true, Mouse: true, BracketedPaste: true, Sixel: true}},
+		{"decrqm", "xterm-256color", "24bit", map[string]string{
+			"\033[c":       "\033[?65;1;9c",
+			"\033[?1000$p": "\033[?1000;2$y",
+			"\033[?2004$p": "\033[?2004;0$y",
+		}, Features{Responded: true, Truecolor: true, Mouse: true}},
+	}
+	for _, tst := range tests {
+		t.Setenv("TERM", tst.term)
+		t.Setenv("COLORTERM", tst.colorterm)
+		tty, err := OpenPTY()
+		if err != nil {
+			t.Fatalf("OpenPTY failed: %v", err)
+		}
+		fakeTerminal(tty, tst.replies)
+		got, err := ProbeFeatures(tty.Slave)
+		if err != nil {
+			t.Errorf("ProbeFeatures %s failed: %v", tst.name, err)
+		}
+		if got != tst.want {
+			t.Errorf("ProbeFeatures %s got: %+v want: %+v", tst.name, got, tst.want)
+		}
+		tty.Close()
+	}
+}
@@ -0,0 +1,542 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned when the user aborts the input with ^C.
+var ErrInterrupted = errors.New("interrupted")
+
+// ctrlC is the ^C key.
+var ctrlC = Key{Code: KeyRune, Rune: 'c', Mod: ModCtrl}
+
+// PromptOption changes how Ask, Confirm and Select read keys, see AbortKeys.
+type PromptOption func(*promptConfig)
+
+// promptConfig are the prompt settings set by the PromptOptions.
+type promptConfig struct {
+	abort []Key
+	esc   bool
+}
+
+// AbortKeys sets the keys aborting Ask, Confirm and Select with ErrInterrupted,
+// replacing the default ^C and Esc, eg. for an app keeping ^C for copy:
+//
+//	term.Confirm(f, "Sure?", false, term.AbortKeys(true, term.Key{Code: term.KeyRune, Rune: 'q', Mod: term.ModCtrl}))
+//
+// esc makes Esc on its own abort as well. Keys match on their Code, Rune and Mod.
+func AbortKeys(esc bool, keys ...Key) PromptOption {
+	return func(c *promptConfig) {
+		c.abort, c.esc = keys, esc
+	}
+}
+
+// newPromptConfig returns the defaults changed by opts.
+func newPromptConfig(opts []PromptOption) promptConfig {
+	c := promptConfig{abort: []Key{ctrlC}, esc: true}
+	for _, o := range opts {
+		o(&c)
+	}
+	return c
+}
+
+// aborts returns true if k is one of the abort keys.
+func (c promptConfig) aborts(k Key) bool {
+	if c.esc && k.Code == KeyEsc && k.Mod == 0 {
+		return true
+	}
+	for _, a := range c.abort {
+		if sameKey(k, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameKey returns true if k and a are the same key, whatever their Event.
+func sameKey(k, a Key) bool {
+	return k.Code == a.Code && k.Rune == a.Rune && k.Mod == a.Mod
+}
+
+// abortEcho returns what's written when k aborts, the control key pressed, eg. "^C",
+// and a line ending.
+func abortEcho(k Key) string {
+	if k.Code == KeyRune && k.Mod == ModCtrl && k.Rune >= 'a' && k.Rune <= 'z' {
+		return "^" + string(k.Rune-'a'+'A') + "\r\n"
+	}
+	return "\r\n"
+}
+
+// LineReader is a simple line editor, the readline of this package.
+// The terminal needs to be in raw mode while reading, see ReadLine.
+//
+// Editing keys:
+//
+//	Left, ^B / Right, ^F	Move one character left / right
+//	Home, ^A / End, ^E	Move to the beginning / end of the line
+//	Backspace / Delete	Delete the character before / under the cursor
+//	^U / ^K			Delete to the beginning / end of the line
+//	^D			Delete the character under the cursor, io.EOF on an empty line
+//	^C			Abort with ErrInterrupted, see Abort
+//	Tab			Insert a tab, expanded to spaces on the screen
+//	^V			Insert the next character as is, eg. ^V ^C a literal ^C
+//	Enter			Accept the line
+//
+// With bracketed paste on, see EnableBracketedPaste, pasted text is read as
+// one unit and inserted at the cursor, with line endings turned into spaces,
+// or handed to OnPaste when set.
+type LineReader struct {
+	// TabWidth is the distance between tab stops, DefaultTabWidth if <= 0.
+	TabWidth int
+	// OnPaste when set gets the pasted text instead of it being inserted.
+	OnPaste func(text string)
+	// Prompt when set is called on every redraw for the prompt to show,
+	// replacing the one given to ReadLine. Eg. for a clock or git branch.
+	Prompt func() string
+	// EchoControl shows control characters on the line, eg. inserted with ^V,
+	// in caret notation like ^C instead of writing them to the terminal as is.
+	EchoControl bool
+	// Abort when set decides which keys abort ReadLine with ErrInterrupted,
+	// replacing ^C. Eg. func(k Key) bool { return k.Code == KeyEsc }.
+	Abort func(Key) bool
+	// KeepLineEnd makes ReadLine return the line with the byte Enter sent at its
+	// end, "\r" or "\n" depending on ICRNL / INLCR, for callers that care which.
+	KeepLineEnd bool
+
+	kr  *KeyReader
+	out io.Writer
+	scr Screen
+}
+
+// NewLineReader returns a LineReader reading and echoing on the terminal f.
+func NewLineReader(f *os.File) *LineReader {
+	return NewLineReaderIO(f, f)
+}
+
+// NewLineReaderIO returns a LineReader reading keys from r and echoing to w, for when
+// input and output aren't the same terminal. It also lets the editing be tested
+// without a terminal, feeding the keys as the bytes a terminal sends:
+//
+//	var out strings.Builder
+//	lr := term.NewLineReaderIO(strings.NewReader("helo\x1b[Dl\r"), &out)
+//	line, err := lr.ReadLine("> ") // "hello", out has the prompt and redraws
+//
+// Once r is used up ReadLine returns io.EOF. Reads from r other than an *os.File
+// can't time out, so an Esc key followed by more input reads as Alt and the next key,
+// see ReadEscapeSequence.
+func NewLineReaderIO(r io.Reader, w io.Writer) *LineReader {
+	kr := NewKeyReader(r)
+	kr.ReadPastes = true
+	return &LineReader{TabWidth: DefaultTabWidth, EchoControl: true, kr: kr, out: w}
+}
+
+// ReadLine prints prompt and reads a line, without the line ending.
+// See PromptIgnoreStart for marking parts of prompt taking up no room.
+//
+// Enter on an empty line returns "" and a nil error. When the input is closed
+// ReadLine returns "" and io.EOF, dropping any partly typed line, as it does
+// for ^D on an empty line. A hung up terminal counts as closed.
+func (lr *LineReader) ReadLine(prompt string) (string, error) {
+	return lr.ReadLineWithInitial(prompt, "")
+}
+
+// ReadLineWithInitial is ReadLine starting out with initial on the line and the
+// cursor at its end, for the user to edit, eg. when renaming something.
+// Enter right away returns initial.
+func (lr *LineReader) ReadLineWithInitial(prompt, initial string) (string, error) {
+	line, err := lr.readLine(prompt, initial)
+	if err == io.ErrUnexpectedEOF || errors.Is(err, syscall.EIO) {
+		// Closed in the middle of a character or hung up.
+		err = io.EOF
+	}
+	return line, err
+}
+
+// readLine is ReadLineWithInitial without the closed input errors made io.EOF.
+func (lr *LineReader) readLine(prompt, initial string) (string, error) {
+	buf := []rune(initial)
+	pos := len(buf)
+	if err := lr.redraw(prompt, buf, pos); err != nil {
+		return "", err
+	}
+	var b [utf8.UTFMax]byte
+	for {
+		k, raw, err := lr.kr.readKey(&b)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case k.Event == KeyRelease:
+			continue
+		case lr.aborts(k):
+			io.WriteString(lr.out, abortEcho(k))
+			return "", ErrInterrupted
+		case k.Code == KeyEnter:
+			_, err := io.WriteString(lr.out, "\r\n")
+			if lr.KeepLineEnd {
+				return string(buf) + lineEnd(raw), err
+			}
+			return string(buf), err
+		case k.Code == KeyRune && k.Mod == 0, k.Code == KeyTab && k.Mod == 0:
+			r := k.Rune
+			if k.Code == KeyTab {
+				r = '\t'
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+		case k.Code == KeyRune && k.Mod == ModCtrl:
+			switch k.Rune {
+			case 'd':
+				if len(buf) == 0 {
+					io.WriteString(lr.out, "\r\n")
+					return "", io.EOF
+				}
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			case 'a':
+				pos = 0
+			case 'e':
+				pos = len(buf)
+			case 'b':
+				if pos > 0 {
+					pos--
+				}
+			case 'f':
+				if pos < len(buf) {
+					pos++
+				}
+			case 'u':
+				buf, pos = buf[pos:], 0
+			case 'k':
+				buf = buf[:pos]
+			case 'v':
+				r, err := lr.readLiteral()
+				if err != nil {
+					return "", err
+				}
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		case k.Code == KeyPaste:
+			if lr.OnPaste != nil {
+				lr.OnPaste(k.Text)
+				continue
+			}
+			paste := []rune(strings.ReplaceAll(k.Text, "\n", " "))
+			buf = append(buf[:pos], append(paste, buf[pos:]...)...)
+			pos += len(paste)
+		case k.Code == KeyBackspace:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case k.Code == KeyDelete:
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case k.Code == KeyLeft:
+			if pos > 0 {
+				pos--
+			}
+		case k.Code == KeyRight:
+			if pos < len(buf) {
+				pos++
+			}
+		case k.Code == KeyHome:
+			pos = 0
+		case k.Code == KeyEnd:
+			pos = len(buf)
+		default:
+			continue
+		}
+		if err := lr.redraw(prompt, buf, pos); err != nil {
+			return "", err
+		}
+	}
+}
+
+// lineEnd returns the line ending Enter sent as raw, "\r" for an escape sequence,
+// eg. the kitty keyboard protocol's.
+func lineEnd(raw []byte) string {
+	if len(raw) == 1 && isLineEnd(raw[0]) {
+		return string(raw)
+	}
+	return "\r"
+}
+
+// aborts returns true if k aborts the line, see Abort.
+func (lr *LineReader) aborts(k Key) bool {
+	if lr.Abort != nil {
+		return lr.Abort(k)
+	}
+	return sameKey(k, ctrlC)
+}
+
+// readLiteral reads the next character as is, without decoding it as a key.
+func (lr *LineReader) readLiteral() (rune, error) {
+	var b [utf8.UTFMax]byte
+	n := 0
+	for n == 0 || b[0] >= utf8.RuneSelf && n < len(b) && !utf8.FullRune(b[:n]) {
+		if _, err := io.ReadFull(lr.kr.src(), b[n:n+1]); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	r, _ := utf8.DecodeRune(b[:n])
+	return r, nil
+}
+
+// caretControls returns s with the control characters other than tab in caret
+// notation, ^@ to ^_ and ^? for DEL.
+func caretControls(s string) string {
+	if !strings.ContainsFunc(s, func(r rune) bool { return r != '\t' && (r < 0x20 || r == EraseDEL) }) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if r != '\t' && (r < 0x20 || r == EraseDEL) {
+			b.WriteString(Caret(byte(r)))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Markers for parts of a prompt that take up no room on the screen,
+// like \[ \] in bash or %{ %} in zsh. Escape sequences are skipped without them,
+// see DisplayWidth, they're for anything else that doesn't move the cursor.
+const (
+	PromptIgnoreStart = "\x01" // PromptIgnoreStart starts a zero width part of a prompt
+	PromptIgnoreEnd   = "\x02" // PromptIgnoreEnd ends it
+)
+
+// promptParts returns prompt as printed, without the ignore markers, and the
+// number of columns it takes up leaving out the parts between the markers.
+func promptParts(prompt string, tabWidth int) (string, int) {
+	if !strings.Contains(prompt, PromptIgnoreStart) {
+		return prompt, DisplayWidthTab(prompt, tabWidth)
+	}
+	var out, visible strings.Builder
+	ignore := false
+	for _, r := range prompt {
+		switch string(r) {
+		case PromptIgnoreStart:
+			ignore = true
+		case PromptIgnoreEnd:
+			ignore = false
+		default:
+			out.WriteRune(r)
+			if !ignore {
+				visible.WriteRune(r)
+			}
+		}
+	}
+	return out.String(), DisplayWidthTab(visible.String(), tabWidth)
+}
+
+// redraw draws the prompt and line again, placing the cursor at pos.
+func (lr *LineReader) redraw(prompt string, buf []rune, pos int) error {
+	tw := lr.TabWidth
+	if tw <= 0 {
+		tw = DefaultTabWidth
+	}
+	if lr.Prompt != nil {
+		prompt = lr.Prompt()
+	}
+	prompt, pw := promptParts(prompt, tw)
+	show := func(s string) string {
+		if lr.EchoControl {
+			s = caretControls(s)
+		}
+		return expandTabs(s, pw, tw)
+	}
+	lr.scr.Print("\r").Print(prompt).Print(show(string(buf))).Print(CSI + "K\r")
+	if col := pw + DisplayWidth(show(string(buf[:pos]))); col > 0 {
+		lr.scr.Print(CSI + strconv.Itoa(col) + "C")
+	}
+	_, err := lr.scr.Flush(lr.out)
+	return err
+}
+
+// ReadLine reads a line from the terminal f, see LineReader.
+// f is set to raw mode while reading and its attributes restored after.
+func ReadLine(f *os.File, prompt string) (string, error) {
+	return Ask(f, prompt, nil)
+}
+
+// Ask prompts for a line from the terminal f until validate accepts it.
+// When validate returns an error it's printed and the user is prompted again.
+// ^C or Esc aborts returning ErrInterrupted, see AbortKeys. A nil validate accepts anything.
+// f is set to raw mode while reading and its attributes restored after.
+func Ask(f *os.File, prompt string, validate func(string) error, opts ...PromptOption) (string, error) {
+	c := newPromptConfig(opts)
+	var line string
+	err := WithRaw(f, func() error {
+		lr := NewLineReader(f)
+		lr.Abort = c.aborts
+		for {
+			var err error
+			if line, err = lr.ReadLine(prompt); err != nil {
+				return err
+			}
+			if validate == nil {
+				return nil
+			}
+			verr := validate(line)
+			if verr == nil {
+				return nil
+			}
+			if _, err := io.WriteString(f, verr.Error()+"\r\n"); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return line, nil
+}
+
+// Confirm asks a yes/no question on the terminal f, returning the answer.
+// The prompt gets " [Y/n] " or " [y/N] " added depending on def and a single
+// key press, y/Y or n/N, answers it without needing Enter. Enter picks def
+// and ^C or Esc aborts with ErrInterrupted, see AbortKeys.
+// f is set to raw mode while reading and its attributes restored after.
+func Confirm(f *os.File, prompt string, def bool, opts ...PromptOption) (bool, error) {
+	c := newPromptConfig(opts)
+	hint := " [y/N] "
+	if def {
+		hint = " [Y/n] "
+	}
+	answer := def
+	err := WithRaw(f, func() error {
+		if _, err := io.WriteString(f, prompt+hint); err != nil {
+			return err
+		}
+		kr := NewKeyReader(f)
+		for {
+			k, err := kr.ReadKey()
+			if err != nil {
+				return err
+			}
+			switch {
+			case k.Event == KeyRelease:
+				continue
+			case c.aborts(k):
+				io.WriteString(f, abortEcho(k))
+				return ErrInterrupted
+			case k.Code == KeyEnter:
+			case k.Code == KeyRune && k.Mod == 0 && (k.Rune == 'y' || k.Rune == 'Y'):
+				answer = true
+			case k.Code == KeyRune && k.Mod == 0 && (k.Rune == 'n' || k.Rune == 'N'):
+				answer = false
+			default:
+				continue
+			}
+			echo := "n\r\n"
+			if answer {
+				echo = "y\r\n"
+			}
+			_, err = io.WriteString(f, echo)
+			return err
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return answer, nil
+}
+
+// Select shows prompt and the options as a menu on the terminal f and returns the
+// index of the one picked. The highlight is moved with the arrow keys or j/k and
+// Enter picks the highlighted option. Esc or ^C aborts with ErrInterrupted, see AbortKeys.
+// The menu is cleared when done, leaving the prompt and the picked option.
+// f is set to raw mode while reading and its attributes restored after.
+func Select(f *os.File, prompt string, options []string, opts ...PromptOption) (int, error) {
+	if len(options) == 0 {
+		return -1, errors.New("no options to select from")
+	}
+	var sel int
+	err := WithRaw(f, func() (err error) {
+		sel, err = selectMenu(f, prompt, options, newPromptConfig(opts))
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return sel, nil
+}
+
+// selectMenu runs the Select menu returning the picked index.
+func selectMenu(f *os.File, prompt string, options []string, c promptConfig) (int, error) {
+	var scr Screen
+	draw := func(sel int) error {
+		for i, o := range options {
+			scr.Print("\r").ClearLine()
+			if i == sel {
+				scr.Print("> ").Color(Reverse).Print(o).Reset()
+			} else {
+				scr.Print("  " + o)
+			}
+			if i < len(options)-1 {
+				scr.Print("\r\n")
+			}
+		}
+		_, err := scr.Flush(f)
+		return err
+	}
+	// done clears the menu and writes the result after the prompt.
+	done := func(res string) error {
+		scr.MoveUp(len(options)).Print("\r").ClearDown().Print(prompt + res + "\r\n").ShowCursor()
+		_, err := scr.Flush(f)
+		return err
+	}
+	scr.HideCursor().Print(prompt + "\r\n")
+	sel := 0
+	if err := draw(sel); err != nil {
+		return -1, err
+	}
+	kr := NewKeyReader(f)
+	for {
+		k, err := kr.ReadKey()
+		if err != nil {
+			done("")
+			return -1, err
+		}
+		switch {
+		case k.Event == KeyRelease:
+			continue
+		case c.aborts(k):
+			done("")
+			return -1, ErrInterrupted
+		case k.Code == KeyUp, k.Code == KeyRune && k.Mod == 0 && k.Rune == 'k':
+			if sel > 0 {
+				sel--
+			}
+		case k.Code == KeyDown, k.Code == KeyRune && k.Mod == 0 && k.Rune == 'j':
+			if sel < len(options)-1 {
+				sel++
+			}
+		case k.Code == KeyEnter:
+			return sel, done(" " + options[sel])
+		default:
+			continue
+		}
+		scr.MoveUp(len(options) - 1)
+		if err := draw(sel); err != nil {
+			return -1, err
+		}
+	}
+}
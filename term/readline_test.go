@@ -0,0 +1,472 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// drain keeps reading the Master so the terminal output never blocks.
+func drain(tty *PTY) *syncBuffer {
+	var out syncBuffer
+	go io.Copy(&out, tty.Master)
+	return &out
+}
+
+// TestLineReader tests the line editing keys.
+func TestLineReader(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hello\r", "hello"},
+		{"hello\n", "hello"},
+		{"\r", ""},
+		{"helo\x1b[Dl\r", "hello"},
+		{"abcx\x7f\r", "abc"},
+		{"bc\x01a\r", "abc"},
+		{"ab\x1b[H\x1b[F\x1b[Dx\r", "axb"},
+		{"axbc\x02\x02\x02\x1b[3~\r", "abc"},
+		{"ab\x02\x02\x06\x06c\x05d\r", "abcd"},
+		{"junk\x15ok\r", "ok"},
+		{"okjunk\x1b[D\x1b[D\x1b[D\x1b[D\x0b\r", "ok"},
+		{"a\x01\x04b\r", "b"},
+		{"日本\x7f語\r", "日語"},
+		{"\x1b[Aup\x1b[B\r", "up"},
+		{"a\tb\r", "a\tb"},
+		{"ab\x1b[D\t\r", "a\tb"},
+		{"ad\x1b[D\x1b[200~b\rc\x1b[201~\r", "ab cd"},
+		{"\x1b[97u\x1b[97;1:3ub\x1b[13u", "ab"},
+	}
+	for _, tst := range tests {
+		tty := rawPTY(t)
+		out := drain(tty)
+		tty.Master.Write([]byte(tst.in))
+		got, err := NewLineReader(tty.Slave).ReadLine("> ")
+		if err != nil {
+			t.Errorf("ReadLine(%q) failed: %v", tst.in, err)
+		}
+		if got != tst.want {
+			t.Errorf("ReadLine(%q) got: %q want: %q", tst.in, got, tst.want)
+		}
+		if !out.waitFor("> ", time.Second) {
+			t.Errorf("ReadLine(%q) output: %q does not contain the prompt", tst.in, out.String())
+		}
+		tty.Close()
+	}
+}
+
+// TestReadLineWithInitial tests editing a pre-filled line.
+func TestReadLineWithInitial(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"\r", "old name"},
+		{"\x7f\x7f\x7f\x7fnew\r", "old new"},
+		{"\x01x\r", "xold name"},
+		{"\x15new\r", "new"},
+	}
+	for _, tst := range tests {
+		lr := &LineReader{kr: NewKeyReader(strings.NewReader(tst.in)), out: io.Discard}
+		got, err := lr.ReadLineWithInitial("> ", "old name")
+		if err != nil || got != tst.want {
+			t.Errorf("ReadLineWithInitial(%q) got: %q, %v want: %q, <nil>", tst.in, got, err, tst.want)
+		}
+	}
+}
+
+// TestNewLineReaderIO tests reading and echoing through plain readers and writers.
+func TestNewLineReaderIO(t *testing.T) {
+	var out strings.Builder
+	lr := NewLineReaderIO(strings.NewReader("helo\x1b[Dl\rbye\r"), &out)
+	for _, want := range []string{"hello", "bye"} {
+		if got, err := lr.ReadLine("> "); err != nil || got != want {
+			t.Errorf("ReadLine got: %q, %v want: %q, <nil>", got, err, want)
+		}
+	}
+	if _, err := lr.ReadLine("> "); err != io.EOF {
+		t.Errorf("ReadLine at the end got: %v want: %v", err, io.EOF)
+	}
+	if want := "\r> helo\x1b[K\r\x1b[5C\r> hello\x1b[K\r\x1b[6C"; !strings.Contains(out.String(), want) {
+		t.Errorf("ReadLine output got: %q want: %q in it", out.String(), want)
+	}
+}
+
+// TestKeepLineEnd tests the line comes with the byte Enter sent.
+func TestKeepLineEnd(t *testing.T) {
+	var out strings.Builder
+	lr := NewLineReaderIO(strings.NewReader("cr\rlf\nkitty\x1b[13u"), &out)
+	lr.KeepLineEnd = true
+	for _, want := range []string{"cr\r", "lf\n", "kitty\r"} {
+		if got, err := lr.ReadLine("> "); err != nil || got != want {
+			t.Errorf("ReadLine with KeepLineEnd got: %q, %v want: %q, <nil>", got, err, want)
+		}
+	}
+}
+
+// TestLineReaderLiteral tests inserting control characters with ^V and showing them.
+func TestLineReaderLiteral(t *testing.T) {
+	tests := []struct {
+		in          string
+		echoControl bool
+		want        string
+		shown       string
+	}{
+		{"a\x16\x03b\r", true, "a\x03b", "> a^Cb\x1b[K"},
+		{"\x16\x1b[A\r", true, "\x1b[A", "> ^[[A\x1b[K"},
+		{"\x16\x7f\x16\x00\r", true, "\x7f\x00", "> ^?^@\x1b[K"},
+		{"\x16\x16\r", true, "\x16", "> ^V\x1b[K"},
+		{"\x16日\r", true, "日", "> 日\x1b[K"},
+		{"a\x16\x03b\r", false, "a\x03b", "> a\x03b\x1b[K"},
+	}
+	for _, tst := range tests {
+		var out strings.Builder
+		lr := NewLineReaderIO(strings.NewReader(tst.in), &out)
+		lr.EchoControl = tst.echoControl
+		got, err := lr.ReadLine("> ")
+		if err != nil || got != tst.want {
+			t.Errorf("ReadLine(%q) got: %q, %v want: %q, <nil>", tst.in, got, err, tst.want)
+		}
+		if !strings.Contains(out.String(), tst.shown) {
+			t.Errorf("ReadLine(%q) output got: %q want: %q in it", tst.in, out.String(), tst.shown)
+		}
+	}
+}
+
+// TestCaretControls tests showing control characters in caret notation.
+func TestCaretControls(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{"a\tb", "a\tb"},
+		{"\x01\x1b\x1f\x7f", "^A^[^_^?"},
+		{"\x00日\x03", "^@日^C"},
+	}
+	for _, tst := range tests {
+		if got := caretControls(tst.in); got != tst.want {
+			t.Errorf("caretControls(%q) got: %q want: %q", tst.in, got, tst.want)
+		}
+	}
+}
+
+// TestLineReaderTabs tests the cursor placement on lines with tabs.
+func TestLineReaderTabs(t *testing.T) {
+	tests := []struct {
+		buf  string
+		pos  int
+		tw   int
+		want string
+	}{
+		{"\tx", 2, 8, "\r> " + "      x" + CSI + "K\r" + CSI + "9C"},
+		{"\tx", 1, 8, "\r> " + "      x" + CSI + "K\r" + CSI + "8C"},
+		{"\tx", 0, 8, "\r> " + "      x" + CSI + "K\r" + CSI + "2C"},
+		{"ab\tx", 3, 4, "\r> " + "ab" + "    x" + CSI + "K\r" + CSI + "8C"},
+		{"abc\t", 4, 4, "\r> " + "abc" + "   " + CSI + "K\r" + CSI + "8C"},
+		{"日\t\t", 3, 4, "\r> " + "日" + "    " + "    " + CSI + "K\r" + CSI + "12C"},
+		{"a\tb", 3, 0, "\r> " + "a" + "     " + "b" + CSI + "K\r" + CSI + "9C"},
+	}
+	for _, tst := range tests {
+		var out strings.Builder
+		lr := &LineReader{TabWidth: tst.tw, out: &out}
+		if err := lr.redraw("> ", []rune(tst.buf), tst.pos); err != nil {
+			t.Fatalf("redraw(%q, %d) failed: %v", tst.buf, tst.pos, err)
+		}
+		if got := out.String(); got != tst.want {
+			t.Errorf("redraw(%q, %d) TabWidth %d got: %q want: %q", tst.buf, tst.pos, tst.tw, got, tst.want)
+		}
+	}
+}
+
+// TestLineReaderOnPaste tests pastes going to OnPaste.
+func TestLineReaderOnPaste(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	drain(tty)
+	tty.Master.Write([]byte("a\x1b[200~x\ry\x1b[201~b\r"))
+	lr := NewLineReader(tty.Slave)
+	var pastes []string
+	lr.OnPaste = func(text string) { pastes = append(pastes, text) }
+	got, err := lr.ReadLine("> ")
+	if err != nil || got != "ab" {
+		t.Errorf("ReadLine got: %q, %v want: %q, <nil>", got, err, "ab")
+	}
+	if len(pastes) != 1 || pastes[0] != "x\ny" {
+		t.Errorf("OnPaste got: %q want: %q", pastes, []string{"x\ny"})
+	}
+}
+
+// TestLineReaderEOF tests telling an empty line from closed input.
+func TestLineReaderEOF(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"\r", []string{""}},
+		{"\r\r", []string{"", ""}},
+		{"a\r\rb\r", []string{"a", "", "b"}},
+		{"\rpartial", []string{""}},
+		{"\r\xe6\x97", []string{""}},
+		{"\r\x1b", []string{""}},
+	}
+	for _, tst := range tests {
+		lr := &LineReader{kr: NewKeyReader(strings.NewReader(tst.in)), out: io.Discard}
+		var got []string
+		var err error
+		for {
+			var line string
+			if line, err = lr.ReadLine("> "); err != nil {
+				break
+			}
+			got = append(got, line)
+		}
+		if err != io.EOF {
+			t.Errorf("ReadLine(%q) got err: %v want: %v", tst.in, err, io.EOF)
+		}
+		if strings.Join(got, "|") != strings.Join(tst.want, "|") || len(got) != len(tst.want) {
+			t.Errorf("ReadLine(%q) got: %q want: %q", tst.in, got, tst.want)
+		}
+	}
+	// A hung up terminal.
+	tty := rawPTY(t)
+	drain(tty)
+	tty.Master.Write([]byte("\r"))
+	lr := NewLineReader(tty.Slave)
+	if line, err := lr.ReadLine("> "); line != "" || err != nil {
+		t.Errorf("ReadLine on PTY got: %q, %v want: \"\", <nil>", line, err)
+	}
+	tty.Master.Close()
+	if line, err := lr.ReadLine("> "); line != "" || err != io.EOF {
+		t.Errorf("ReadLine on closed PTY got: %q, %v want: \"\", %v", line, err, io.EOF)
+	}
+	tty.Slave.Close()
+}
+
+// TestPromptParts tests the prompt width with zero width parts.
+func TestPromptParts(t *testing.T) {
+	tests := []struct {
+		in    string
+		out   string
+		width int
+	}{
+		{"> ", "> ", 2},
+		{Green("$ ").String(), Green("$ ").String(), 2},
+		{"\x01\x1b]0;title\a\x02> ", "\x1b]0;title\a> ", 2},
+		{"\x01hidden\x02> ", "hidden> ", 2},
+		{"a\x01b\x02c\x01d", "abcd", 2},
+		{"日本\x01\x1b[1m\x02> ", "日本\x1b[1m> ", 6},
+	}
+	for _, tst := range tests {
+		out, width := promptParts(tst.in, DefaultTabWidth)
+		if out != tst.out || width != tst.width {
+			t.Errorf("promptParts(%q) got: %q, %d want: %q, %d", tst.in, out, width, tst.out, tst.width)
+		}
+	}
+}
+
+// TestLineReaderPrompt tests the Prompt callback being used on every redraw.
+func TestLineReaderPrompt(t *testing.T) {
+	var out strings.Builder
+	n := 0
+	lr := &LineReader{kr: NewKeyReader(strings.NewReader("ab\r")), out: &out}
+	lr.Prompt = func() string {
+		n++
+		return PromptIgnoreStart + "~~" + PromptIgnoreEnd + strconv.Itoa(n) + "> "
+	}
+	line, err := lr.ReadLine("unused")
+	if err != nil || line != "ab" {
+		t.Fatalf("ReadLine got: %q, %v want: %q, <nil>", line, err, "ab")
+	}
+	if n != 3 {
+		t.Errorf("Prompt called: %d times want: 3", n)
+	}
+	want := "\r~~1> " + CSI + "K\r" + CSI + "3C" +
+		"\r~~2> a" + CSI + "K\r" + CSI + "4C" +
+		"\r~~3> ab" + CSI + "K\r" + CSI + "5C\r\n"
+	if got := out.String(); got != want {
+		t.Errorf("ReadLine output got: %q want: %q", got, want)
+	}
+}
+
+// TestLineReaderAbort tests ^C and ^D on an empty line.
+func TestLineReaderAbort(t *testing.T) {
+	for _, tst := range []struct {
+		in   string
+		want error
+	}{
+		{"abc\x03", ErrInterrupted},
+		{"\x04", io.EOF},
+	} {
+		tty := rawPTY(t)
+		drain(tty)
+		tty.Master.Write([]byte(tst.in))
+		if _, err := NewLineReader(tty.Slave).ReadLine("> "); err != tst.want {
+			t.Errorf("ReadLine(%q) got: %v want: %v", tst.in, err, tst.want)
+		}
+		tty.Close()
+	}
+}
+
+// TestAsk tests prompting until the input validates.
+func TestAsk(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	out := drain(tty)
+	orig, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	validate := func(s string) error {
+		if !strings.HasPrefix(s, "ok") {
+			return errors.New("must start with ok")
+		}
+		return nil
+	}
+	tty.Master.Write([]byte("bad\rok then\r"))
+	got, err := Ask(tty.Slave, "Answer: ", validate)
+	if err != nil {
+		t.Fatalf("Ask failed: %v", err)
+	}
+	if got != "ok then" {
+		t.Errorf("Ask got: %q want: %q", got, "ok then")
+	}
+	if !out.waitFor("must start with ok", time.Second) {
+		t.Errorf("Ask output: %q does not contain the validation error", out.String())
+	}
+	if after, err := Attr(tty.Slave); err != nil || after != orig {
+		t.Errorf("Ask did not restore the attributes got: %+v, %v want: %+v", after, err, orig)
+	}
+	tty.Master.Write([]byte("bad\r\x03"))
+	if _, err := Ask(tty.Slave, "Answer: ", validate); err != ErrInterrupted {
+		t.Errorf("Ask with ^C got: %v want: %v", err, ErrInterrupted)
+	}
+	nf, err := donormfile("TestAsk")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if _, err := Ask(nf, "Answer: ", nil); err == nil {
+		t.Error("Ask on a regular file got: <nil> want: error")
+	}
+}
+
+// TestConfirm tests the yes/no answers.
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		in   string
+		def  bool
+		want bool
+		err  error
+	}{
+		{"y", false, true, nil},
+		{"Y", false, true, nil},
+		{"n", true, false, nil},
+		{"N", true, false, nil},
+		{"\r", true, true, nil},
+		{"\r", false, false, nil},
+		{"xq\x1b[Ay", false, true, nil},
+		{"\x03", true, false, ErrInterrupted},
+	}
+	for _, tst := range tests {
+		tty := rawPTY(t)
+		out := drain(tty)
+		tty.Master.Write([]byte(tst.in))
+		got, err := Confirm(tty.Slave, "Sure?", tst.def)
+		if got != tst.want || err != tst.err {
+			t.Errorf("Confirm(%q, %t) got: %t, %v want: %t, %v", tst.in, tst.def, got, err, tst.want, tst.err)
+		}
+		hint := "Sure? [y/N] "
+		if tst.def {
+			hint = "Sure? [Y/n] "
+		}
+		if !out.waitFor(hint, time.Second) {
+			t.Errorf("Confirm(%q, %t) output: %q want: %q", tst.in, tst.def, out.String(), hint)
+		}
+		tty.Close()
+	}
+}
+
+// TestSelect tests moving around and picking from a menu.
+func TestSelect(t *testing.T) {
+	options := []string{"one", "two", "three"}
+	tests := []struct {
+		in   string
+		want int
+		err  error
+	}{
+		{"\r", 0, nil},
+		{"j\r", 1, nil},
+		{"j\x1b[B\r", 2, nil},
+		{"jjjjj\r", 2, nil},
+		{"k\x1b[A\r", 0, nil},
+		{"jjk\r", 1, nil},
+		{"j\x1b", -1, ErrInterrupted},
+		{"\x03", -1, ErrInterrupted},
+	}
+	for _, tst := range tests {
+		tty := rawPTY(t)
+		out := drain(tty)
+		tty.Master.Write([]byte(tst.in))
+		got, err := Select(tty.Slave, "Pick one:", options)
+		if got != tst.want || err != tst.err {
+			t.Errorf("Select(%q) got: %d, %v want: %d, %v", tst.in, got, err, tst.want, tst.err)
+		}
+		if tst.err == nil && !out.waitFor("Pick one: "+options[tst.want]+"\r\n", time.Second) {
+			t.Errorf("Select(%q) output: %q does not end with the picked option", tst.in, out.String())
+		}
+		tty.Close()
+	}
+	if _, err := Select(os.Stdin, "Pick one:", nil); err == nil {
+		t.Error("Select with no options got: <nil> want: error")
+	}
+}
+
+// TestAbortKeys tests changing the keys aborting the prompts.
+func TestAbortKeys(t *testing.T) {
+	ctrlQ := Key{Code: KeyRune, Rune: 'q', Mod: ModCtrl}
+	tests := []struct {
+		name string
+		in   string
+		opts []PromptOption
+		err  error
+		echo string
+	}{
+		{"default ^C", "\x03", nil, ErrInterrupted, "^C\r\n"},
+		{"default Esc", "\x1b", nil, ErrInterrupted, ""},
+		{"^Q", "\x11", []PromptOption{AbortKeys(false, ctrlQ)}, ErrInterrupted, "^Q\r\n"},
+		{"^C ignored", "\x03y", []PromptOption{AbortKeys(false, ctrlQ)}, nil, "y\r\n"},
+	}
+	for _, tst := range tests {
+		tty := rawPTY(t)
+		out := drain(tty)
+		tty.Master.Write([]byte(tst.in))
+		if _, err := Confirm(tty.Slave, "Sure?", false, tst.opts...); err != tst.err {
+			t.Errorf("Confirm %s got: %v want: %v", tst.name, err, tst.err)
+		}
+		if !out.waitFor("Sure? [y/N] "+tst.echo, time.Second) {
+			t.Errorf("Confirm %s output: %q want: %q", tst.name, out.String(), tst.echo)
+		}
+		tty.Close()
+	}
+	tty := rawPTY(t)
+	defer tty.Close()
+	drain(tty)
+	tty.Master.Write([]byte("ab\x11"))
+	if _, err := Ask(tty.Slave, "> ", nil, AbortKeys(false, ctrlQ)); err != ErrInterrupted {
+		t.Errorf("Ask with ^Q got: %v want: %v", err, ErrInterrupted)
+	}
+	tty.Master.Write([]byte("jj\x11"))
+	if _, err := Select(tty.Slave, "Pick one:", []string{"a", "b", "c"}, AbortKeys(false, ctrlQ)); err != ErrInterrupted {
+		t.Errorf("Select with ^Q got: %v want: %v", err, ErrInterrupted)
+	}
+}
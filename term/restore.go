@@ -0,0 +1,162 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// RegisterRestore saves the current attributes of f and makes sure they're set back
+// if the program gets killed by SIGINT or SIGTERM. After restoring, the signal is
+// raised again with the default handling so the program dies like it otherwise would have.
+//
+// The returned function sets the saved attributes back and stops watching for the signals,
+// normally deferred right after the call.
+//
+//	restore, err := term.RegisterRestore(os.Stdin)
+//	if err != nil {
+//		return err
+//	}
+//	defer restore()
+//
+// Nothing can be done for os.Exit, from any goroutine, since it kills the program
+// without running deferred functions or signal handlers.
+func RegisterRestore(f *os.File) (func() error, error) {
+	t, err := Attr(f)
+	if err != nil {
+		return nil, err
+	}
+	stop := trapRestore(f, t, []os.Signal{syscall.SIGINT, syscall.SIGTERM})
+	return func() error {
+		stop()
+		return t.Set(f)
+	}, nil
+}
+
+// TrapRestore saves the current attributes of f and, on any of the signals, sets them
+// back and raises the signal again with the default handling, so the program dies with
+// the exit status it would have had. With no signals SIGINT, SIGTERM, SIGHUP and SIGQUIT
+// are trapped. Signals that can't be caught, eg. SIGKILL, can't be dealt with.
+//
+// A goroutine waits for the signals until stop is called, stop does not restore the
+// attributes, see RegisterRestore for that. stop can be called more than once.
+// If the attributes of f can't be read, eg. f is not a tty, nothing is trapped.
+//
+//	defer term.TrapRestore(os.Stdin)()
+func TrapRestore(f *os.File, signals ...os.Signal) (stop func()) {
+	t, err := Attr(f)
+	if err != nil {
+		return func() {}
+	}
+	if len(signals) == 0 {
+		signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+	}
+	return trapRestore(f, t, signals)
+}
+
+// trapRestore sets t on f when getting one of the signals, then raises it again.
+func trapRestore(f *os.File, t Termios, signals []os.Signal) func() {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, signals...)
+	go func() {
+		select {
+		case sig := <-sigs:
+			t.Set(f)
+			signal.Reset(sig)
+			if s, ok := sig.(syscall.Signal); ok {
+				syscall.Kill(syscall.Getpid(), s)
+			}
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+}
+
+// WithRaw sets f to raw mode, runs fn and sets the attributes f had back when fn
+// returns, also if it panics. This is the recommended way of doing raw mode work
+// that's only needed for a while, eg. reading a single key.
+//
+// Returns the error from fn, or if fn succeeded, any error restoring the attributes.
+func WithRaw(f *os.File, fn func() error) (err error) {
+	t, err := Attr(f)
+	if err != nil {
+		return err
+	}
+	raw := t
+	raw.Raw()
+	if err := raw.Set(f); err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := t.Set(f); err == nil {
+			err = rerr
+		}
+	}()
+	return fn()
+}
+
+// RawSession is a terminal in raw mode for a keyboard driven program: keys are read
+// with the KeyReader, escape sequences written with Write and Close sets the terminal
+// back the way it was.
+//
+//	s, err := term.NewRawSession(os.Stdin)
+//	if err != nil {
+//		return err
+//	}
+//	defer s.Close()
+//	k, err := s.ReadKey()
+//
+// Being killed by SIGINT, SIGTERM, SIGHUP or SIGQUIT restores the terminal as well,
+// see TrapRestore.
+type RawSession struct {
+	*KeyReader // KeyReader reads the keys from the terminal
+
+	f    *os.File
+	orig Termios
+	stop func()
+	once sync.Once
+	err  error
+}
+
+// NewRawSession saves the attributes of the terminal f and sets it to raw mode.
+func NewRawSession(f *os.File) (*RawSession, error) {
+	t, err := Attr(f)
+	if err != nil {
+		return nil, err
+	}
+	raw := t
+	raw.Raw()
+	stop := trapRestore(f, t, []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT})
+	if err := raw.Set(f); err != nil {
+		stop()
+		return nil, err
+	}
+	return &RawSession{KeyReader: NewKeyReader(f), f: f, orig: t, stop: stop}, nil
+}
+
+// Write writes b to the terminal, eg. output built up with a Screen.
+func (s *RawSession) Write(b []byte) (int, error) {
+	return s.f.Write(b)
+}
+
+// Close sets the attributes the terminal had before NewRawSession back.
+// Calling Close again does nothing and returns the same error.
+func (s *RawSession) Close() error {
+	s.once.Do(func() {
+		s.stop()
+		s.err = s.orig.Set(s.f)
+	})
+	return s.err
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestRegisterRestore tests restoring the attributes with the returned function.
+func TestRegisterRestore(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	orig, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	restore, err := RegisterRestore(tty.Slave)
+	if err != nil {
+		t.Fatalf("RegisterRestore failed: %v", err)
+	}
+	raw := orig
+	raw.Raw()
+	if err := raw.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := restore(); err != nil {
+			t.Fatalf("restore failed: %v", err)
+		}
+		got, err := Attr(tty.Slave)
+		if err != nil {
+			t.Fatalf("Attr failed: %v", err)
+		}
+		if got != orig {
+			t.Errorf("restore got: %+v want: %+v", got, orig)
+		}
+	}
+	nf, err := donormfile("TestRegisterRestore")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if _, err := RegisterRestore(nf); err == nil {
+		t.Error("RegisterRestore on a regular file got: <nil> want: error")
+	}
+}
+
+// TestTrapRestore tests the attributes are set back when the program is killed by
+// a trapped signal, and it still dies from the signal. The program killed is the test
+// binary itself, running TestTrapRestoreChild.
+func TestTrapRestore(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	orig, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTrapRestoreChild$")
+	cmd.Env = append(os.Environ(), "TERM_TRAP_RESTORE_CHILD=1")
+	cmd.ExtraFiles = []*os.File{tty.Slave}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe failed: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting child failed: %v", err)
+	}
+	if line, err := bufio.NewReader(out).ReadString('\n'); err != nil || line != "ready\n" {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Fatalf("child got: %q, %v want: %q, <nil>", line, err, "ready\n")
+	}
+	if got, _ := Attr(tty.Slave); !got.IsRaw() {
+		t.Error("child did not set raw mode")
+	}
+	cmd.Process.Signal(syscall.SIGTERM)
+	err = cmd.Wait()
+	ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGTERM {
+		t.Errorf("TrapRestore child exit got: %v want: signal: terminated", err)
+	}
+	if got, _ := Attr(tty.Slave); got != orig {
+		t.Errorf("TrapRestore got: %+v want: %+v", got, orig)
+	}
+}
+
+// TestTrapRestoreChild is the program killed by TestTrapRestore, it traps SIGTERM
+// for the Slave passed in as fd 3 and sets it to raw mode.
+func TestTrapRestoreChild(t *testing.T) {
+	if os.Getenv("TERM_TRAP_RESTORE_CHILD") != "1" {
+		t.Skip("only run by TestTrapRestore")
+	}
+	f := os.NewFile(3, "slave")
+	stop := TrapRestore(f, syscall.SIGTERM)
+	defer stop()
+	tr, err := Attr(f)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Raw()
+	if err := tr.Set(f); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	os.Stdout.WriteString("ready\n")
+	time.Sleep(10 * time.Second)
+	t.Error("not killed by SIGTERM")
+}
+
+// TestWithRaw tests the attributes are restored after fn, also on a panic.
+func TestWithRaw(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	orig, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	fnErr := errors.New("fn failed")
+	err = WithRaw(tty.Slave, func() error {
+		tr, err := Attr(tty.Slave)
+		if err != nil {
+			return err
+		}
+		if !tr.IsRaw() {
+			t.Error("WithRaw did not set raw mode while running fn")
+		}
+		return fnErr
+	})
+	if err != fnErr {
+		t.Errorf("WithRaw got: %v want: %v", err, fnErr)
+	}
+	if got, _ := Attr(tty.Slave); got != orig {
+		t.Errorf("WithRaw did not restore attributes got: %+v want: %+v", got, orig)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithRaw swallowed the panic")
+			}
+		}()
+		WithRaw(tty.Slave, func() error { panic("boom") })
+	}()
+	if got, _ := Attr(tty.Slave); got != orig {
+		t.Errorf("WithRaw did not restore attributes on panic got: %+v want: %+v", got, orig)
+	}
+}
+
+// TestRawSession tests reading keys in raw mode and restoring on Close.
+func TestRawSession(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	orig, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	s, err := NewRawSession(tty.Slave)
+	if err != nil {
+		t.Fatalf("NewRawSession failed: %v", err)
+	}
+	if tr, _ := Attr(tty.Slave); !tr.IsRaw() {
+		t.Error("NewRawSession did not set raw mode")
+	}
+	tty.Master.Write([]byte("\033[A"))
+	if k, err := s.ReadKey(); k.Code != KeyUp || err != nil {
+		t.Errorf("ReadKey got: %+v, %v want: KeyUp, <nil>", k, err)
+	}
+	if _, err := s.Write([]byte("out")); err != nil {
+		t.Errorf("Write failed: %v", err)
+	}
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(tty.Master, b); err != nil || string(b) != "out" {
+		t.Errorf("Write got: %q, %v want: %q, <nil>", b, err, "out")
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+		if got, _ := Attr(tty.Slave); got != orig {
+			t.Errorf("Close got: %+v want: %+v", got, orig)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// Screen batches up terminal output and escape sequences so they can be written
+// with a single Write. This keeps the number of syscalls down and avoids tearing
+// when redrawing.
+//
+//	var s term.Screen
+//	s.MoveTo(1, 1).ClearLine().Color(term.FgRed, term.Bld).Print("Alert!").Reset()
+//	s.Flush(os.Stdout)
+//
+// The zero value is an empty Screen ready to use.
+type Screen struct {
+	buf []byte
+}
+
+// MoveTo moves the cursor to row, col. The top left corner is 1, 1.
+func (s *Screen) MoveTo(row, col int) *Screen {
+	s.buf = append(s.buf, CSI...)
+	s.buf = strconv.AppendInt(s.buf, int64(row), 10)
+	s.buf = append(s.buf, ';')
+	s.buf = strconv.AppendInt(s.buf, int64(col), 10)
+	s.buf = append(s.buf, 'H')
+	return s
+}
+
+// MoveUp moves the cursor up n rows.
+func (s *Screen) MoveUp(n int) *Screen {
+	if n <= 0 {
+		return s
+	}
+	s.buf = append(s.buf, CSI...)
+	s.buf = strconv.AppendInt(s.buf, int64(n), 10)
+	s.buf = append(s.buf, 'A')
+	return s
+}
+
+// HideCursor makes the cursor invisible.
+func (s *Screen) HideCursor() *Screen {
+	s.buf = append(s.buf, CSI+"?25l"...)
+	return s
+}
+
+// ShowCursor makes the cursor visible again.
+func (s *Screen) ShowCursor() *Screen {
+	s.buf = append(s.buf, CSI+"?25h"...)
+	return s
+}
+
+// ClearLine clears the whole line the cursor is on.
+func (s *Screen) ClearLine() *Screen {
+	s.buf = append(s.buf, CSI+"2K"...)
+	return s
+}
+
+// ClearDown clears from the cursor to the end of the screen.
+func (s *Screen) ClearDown() *Screen {
+	s.buf = append(s.buf, CSI+"J"...)
+	return s
+}
+
+// ClearScreen clears the whole screen.
+func (s *Screen) ClearScreen() *Screen {
+	s.buf = append(s.buf, CSI+"2J"...)
+	return s
+}
+
+// Color sets the modes, eg. FgRed, BgBlue or Bld, for the text that follows.
+// Nothing is added when the colors are disabled with ColorDisable.
+func (s *Screen) Color(mods ...string) *Screen {
+	if !colorEnable || len(mods) == 0 {
+		return s
+	}
+	s.buf = append(s.buf, CSI...)
+	for i, m := range mods {
+		if i > 0 {
+			s.buf = append(s.buf, ';')
+		}
+		s.buf = append(s.buf, m...)
+	}
+	s.buf = append(s.buf, 'm')
+	return s
+}
+
+// Reset sets all the modes back to the terminal defaults.
+func (s *Screen) Reset() *Screen {
+	if !colorEnable {
+		return s
+	}
+	s.buf = append(s.buf, CSI+NoMode+"m"...)
+	return s
+}
+
+// Print adds str as is.
+func (s *Screen) Print(str string) *Screen {
+	s.buf = append(s.buf, str...)
+	return s
+}
+
+// Len returns the number of bytes waiting to be flushed.
+func (s *Screen) Len() int {
+	return len(s.buf)
+}
+
+// String returns the batched up output.
+func (s *Screen) String() string {
+	return string(s.buf)
+}
+
+// Flush writes all the batched up output to w in one go and empties the Screen.
+func (s *Screen) Flush(w io.Writer) (int, error) {
+	n, err := w.Write(s.buf)
+	s.buf = s.buf[:0]
+	return n, err
+}
+
+// FlushTo is Flush for a terminal f, the output goes out in a single write(2), more
+// only when the terminal takes part of it. The buffer is kept for the next frame,
+// once it has grown to the size of one a render loop batching up and flushing
+// frames allocates nothing, see BenchmarkScreenFlushTo.
+func (s *Screen) FlushTo(f *os.File) (int, error) {
+	return s.Flush(f)
+}
+
+// SetTabStop sets a horizontal tab stop at the cursor column, "\033H" (HTS).
+func SetTabStop(w io.Writer) error {
+	_, err := io.WriteString(w, "\033H")
+	return err
+}
+
+// ClearTabStop clears the tab stop at the cursor column, "\033[0g".
+func ClearTabStop(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"0g")
+	return err
+}
+
+// ClearAllTabStops clears every tab stop, "\033[3g". Tabs then move to the
+// last column until new ones are set with SetTabStop.
+func ClearAllTabStops(w io.Writer) error {
+	_, err := io.WriteString(w, CSI+"3g")
+	return err
+}
+
+// SetScrollRegion limits scrolling to the rows top to bottom, "\033[top;bottomr" (DECSTBM).
+// Rows count from 1, top or bottom <= 0 resets the region to the whole screen.
+// Terminals move the cursor to the top left corner when the region is set.
+func SetScrollRegion(w io.Writer, top, bottom int) error {
+	seq := CSI + "r"
+	if top > 0 && bottom > 0 {
+		seq = CSI + strconv.Itoa(top) + ";" + strconv.Itoa(bottom) + "r"
+	}
+	_, err := io.WriteString(w, seq)
+	return err
+}
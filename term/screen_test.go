@@ -0,0 +1,122 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// TestScreen tests building up escape sequences with the Screen type.
+func TestScreen(t *testing.T) {
+	var s Screen
+	s.MoveTo(3, 14).ClearLine().Color(FgRed, Bld).Print("Alert!").Reset().ClearScreen()
+	want := "\033[3;14H\033[2K\033[31;1mAlert!\033[0m\033[2J"
+	if got := s.String(); got != want {
+		t.Errorf("Screen got: %q want: %q", got, want)
+	}
+	if s.Len() != len(want) {
+		t.Errorf("Len() got: %d want: %d", s.Len(), len(want))
+	}
+	var out bytes.Buffer
+	n, err := s.Flush(&out)
+	if err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n != len(want) || out.String() != want {
+		t.Errorf("Flush got: %d %q want: %d %q", n, out.String(), len(want), want)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() after Flush got: %d want: 0", s.Len())
+	}
+	s.MoveUp(2).MoveUp(0).HideCursor().ShowCursor().ClearDown()
+	if want := "\033[2A\033[?25l\033[?25h\033[J"; s.String() != want {
+		t.Errorf("Screen got: %q want: %q", s.String(), want)
+	}
+	s.Flush(&out)
+	ColorDisable()
+	defer ColorEnable()
+	s.Color(FgGreen).Print("plain").Reset()
+	if got := s.String(); got != "plain" {
+		t.Errorf("Screen with colors disabled got: %q want: %q", got, "plain")
+	}
+}
+
+// TestTabStopsAndScrollRegion tests the tab stop and scroll region sequences.
+func TestTabStopsAndScrollRegion(t *testing.T) {
+	var out bytes.Buffer
+	SetTabStop(&out)
+	ClearTabStop(&out)
+	ClearAllTabStops(&out)
+	SetScrollRegion(&out, 2, 23)
+	SetScrollRegion(&out, 0, 0)
+	if want := "\033H\033[0g\033[3g\033[2;23r\033[r"; out.String() != want {
+		t.Errorf("tab stops and scroll region got: %q want: %q", out.String(), want)
+	}
+}
+
+// frame batches up a frame the way a render loop would.
+func frame(s *Screen, n int) {
+	s.HideCursor()
+	for row := 1; row <= n; row++ {
+		s.MoveTo(row, 1).ClearLine().Color(FgGreen, Bld).Print("status line").Reset()
+	}
+	s.ShowCursor()
+}
+
+// TestFlushTo tests flushing to a terminal and reusing the buffer.
+func TestFlushTo(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	var s Screen
+	s.MoveTo(2, 5).Print("hi")
+	want := "\033[2;5Hhi"
+	if n, err := s.FlushTo(tty.Slave); err != nil || n != len(want) {
+		t.Fatalf("FlushTo got: %d, %v want: %d, <nil>", n, err, len(want))
+	}
+	b := make([]byte, len(want))
+	if _, err := io.ReadFull(tty.Master, b); err != nil || string(b) != want {
+		t.Errorf("Master got: %q, %v want: %q, <nil>", b, err, want)
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len() after FlushTo got: %d want: 0", s.Len())
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Opening %s failed: %v", os.DevNull, err)
+	}
+	defer null.Close()
+	frame(&s, 24)
+	s.FlushTo(null)
+	allocs := testing.AllocsPerRun(100, func() {
+		frame(&s, 24)
+		s.FlushTo(null)
+	})
+	if allocs != 0 {
+		t.Errorf("FlushTo allocations per frame got: %v want: 0", allocs)
+	}
+}
+
+// BenchmarkScreenFlushTo measures batching up and flushing a 24 line frame.
+func BenchmarkScreenFlushTo(b *testing.B) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatalf("Opening %s failed: %v", os.DevNull, err)
+	}
+	defer null.Close()
+	var s Screen
+	frame(&s, 24)
+	s.FlushTo(null)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		frame(&s, 24)
+		if _, err := s.FlushTo(null); err != nil {
+			b.Fatalf("FlushTo failed: %v", err)
+		}
+	}
+}
@@ -4,7 +4,15 @@
 
 package term
 
-import "syscall"
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
 
 const (
 	// Terminal attribute types.
@@ -170,10 +178,21 @@ func (t *Termios) ToSSH() map[uint8]uint32 {
 }
 
 // FromSSH converts SSH attributes to Termios attributes.
+// The client's erase char, VERASE, is taken as is, see SetErase and NormalizeErase
+// for when the client and local terminal disagree on ^H vs ^?, and ReconcileErase
+// for making the erase and line ending flags agree with it.
+// TTY_OP_END and opcodes without a Termios counterpart are ignored.
+//
+// Only the modes in termModes are changed, everything else in t is kept as it was,
+// so FromSSH merges the client's modes onto t. See FromSSHReset for a clean start.
 func (t *Termios) FromSSH(termModes map[uint8]uint32) {
 	var flags *uint32
 	for sshID, val := range termModes {
-		switch convertSSH[sshID].tType {
+		conv, ok := convertSSH[sshID]
+		if !ok {
+			continue
+		}
+		switch conv.tType {
 		case sshIflag:
 			flags = &t.Iflag
 		case sshOflag:
@@ -183,7 +202,7 @@ func (t *Termios) FromSSH(termModes map[uint8]uint32) {
 		case sshCflag:
 			flags = &t.Cflag
 		case sshCchar:
-			t.Cc[convertSSH[sshID].native] = byte(val)
+			t.Cc[conv.native] = byte(val)
 			continue
 		case sshTspeed:
 			if sshID == sshTTYOPISPEED {
@@ -196,9 +215,174 @@ func (t *Termios) FromSSH(termModes map[uint8]uint32) {
 			continue
 		}
 		if val > 0 {
-			*flags |= convertSSH[sshID].native
+			*flags |= conv.native
 		} else {
-			*flags &^= convertSSH[sshID].native
+			*flags &^= conv.native
+		}
+	}
+}
+
+// FromSSHReset sets t to the defaults of a newly opened Linux terminal, then
+// applies termModes with FromSSH. Unlike FromSSH nothing of the flags or control
+// characters t had before is kept, only the speeds and the window size.
+func (t *Termios) FromSSHReset(termModes map[uint8]uint32) {
+	t.Iflag = syscall.ICRNL | syscall.IXON
+	t.Oflag = syscall.OPOST | syscall.ONLCR
+	t.Cflag = t.Cflag&^(syscall.CSIZE|syscall.CSTOPB|syscall.PARENB|syscall.PARODD) | syscall.CS8 | syscall.CREAD
+	t.Lflag = syscall.ISIG | syscall.ICANON | syscall.ECHO | syscall.ECHOE | syscall.ECHOK |
+		syscall.ECHOCTL | syscall.ECHOKE | syscall.IEXTEN
+	t.Cc = defaultCc
+	t.FromSSH(termModes)
+}
+
+// defaultCc are the control characters of a newly opened Linux terminal.
+var defaultCc = func() (cc [tNCCS]byte) {
+	cc[syscall.VINTR] = 'C' & 0x1f
+	cc[syscall.VQUIT] = '\\' & 0x1f
+	cc[syscall.VERASE] = EraseDEL
+	cc[syscall.VKILL] = 'U' & 0x1f
+	cc[syscall.VEOF] = 'D' & 0x1f
+	cc[syscall.VMIN] = 1
+	cc[syscall.VSTART] = 'Q' & 0x1f
+	cc[syscall.VSTOP] = 'S' & 0x1f
+	cc[syscall.VSUSP] = 'Z' & 0x1f
+	cc[syscall.VREPRINT] = 'R' & 0x1f
+	cc[syscall.VDISCARD] = 'O' & 0x1f
+	cc[syscall.VWERASE] = 'W' & 0x1f
+	cc[syscall.VLNEXT] = 'V' & 0x1f
+	return cc
+}()
+
+// sshUnsafe are the SSH modes ApplySSHSafe ignores.
+var sshUnsafe = map[uint8]bool{
+	sshVDSUSP: true, // Linux has no VDSUSP, FromSSH writes it to Cc[6], which is VMIN
+	sshIUCLC:  true, // Upper to lower case conversion, from terminals without lower case
+	sshXCASE:  true,
+	sshOLCUC:  true,
+	sshCS7:    true, // Character size and parity, a PTY needs 8 bit clean characters
+	sshCS8:    true,
+	sshPARENB: true,
+	sshPARODD: true,
+}
+
+// ApplySSHSafe is FromSSH for modes sent by a client that's not trusted to send
+// sane ones, returning the opcodes of the modes ignored, in ascending order.
+//
+// Ignored are the character size and parity modes (CS7, CS8, PARENB, PARODD), the
+// upper case conversions (IUCLC, XCASE, OLCUC), VDSUSP which Linux does not have, and
+// control characters set to '\r' or '\n' which would take over the Enter key, other
+// than VEOL and VEOL2 that are meant for that. Opcodes FromSSH ignores are returned too.
+//
+// The speeds, baud rates in SSH, are clamped to the Linux B* codes the way Attr returns
+// them in Ispeed and Ospeed: the highest rate not above the one asked for, so 4000000
+// for anything above that, and 50 for anything below. A speed of 0, hang up, is ignored.
+// As with ApplySTTY the codes go in Cflag too, where Set takes them from: the output
+// speed in the CBAUD bits and the input speed in the CIBAUD bits.
+func (t *Termios) ApplySSHSafe(m map[uint8]uint32) []uint8 {
+	var dropped []uint8
+	safe := make(map[uint8]uint32, len(m))
+	for _, op := range SSHModeOpcodesSorted(m) {
+		val := m[op]
+		conv, ok := convertSSH[op]
+		switch {
+		case op == sshTTYOPEND:
+			continue
+		case !ok || sshUnsafe[op] || conv.tType == sshNOP:
+			dropped = append(dropped, op)
+			continue
+		case conv.tType == sshCchar && (val == '\r' || val == '\n') && op != sshVEOL && op != sshVEOL2:
+			dropped = append(dropped, op)
+			continue
+		case conv.tType == sshTspeed:
+			code, ok := speedCode(val)
+			if !ok {
+				dropped = append(dropped, op)
+				continue
+			}
+			if op == sshTTYOPISPEED {
+				t.Ispeed = code
+				t.Cflag = t.Cflag&^unix.CIBAUD | code<<unix.IBSHIFT
+			} else {
+				t.Ospeed = code
+				t.Cflag = t.Cflag&^(unix.CBAUD|unix.CBAUDEX) | code
+			}
+			continue
+		}
+		safe[op] = val
+	}
+	t.FromSSH(safe)
+	return dropped
+}
+
+// speedCode returns the B* code of the highest baud rate not above baud, the lowest
+// rate for one below all of them. Returns false for 0.
+func speedCode(baud uint32) (uint32, bool) {
+	if baud == 0 {
+		return 0, false
+	}
+	best, bestRate := uint32(0), uint64(0)
+	low, lowRate := uint32(0), uint64(0)
+	for code, name := range speedNames {
+		rate, err := strconv.ParseUint(name, 10, 32)
+		if err != nil || rate == 0 {
+			continue
+		}
+		if rate <= uint64(baud) && rate > bestRate {
+			best, bestRate = code, rate
+		}
+		if lowRate == 0 || rate < lowRate {
+			low, lowRate = code, rate
+		}
+	}
+	if bestRate == 0 {
+		return low, true
+	}
+	return best, true
+}
+
+// SSHModeOpcodesSorted returns the opcodes of the SSH modes m in ascending order.
+func SSHModeOpcodesSorted(m map[uint8]uint32) []uint8 {
+	ops := make([]uint8, 0, len(m))
+	for op := range m {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	return ops
+}
+
+// EncodeSSHModes encodes the SSH modes m into the "encoded terminal modes" of
+// the SSH pty-req, RFC 4254 section 8: opcode byte, uint32 argument, ending with TTY_OP_END.
+// The opcodes are written in ascending order so the same modes always encode the same.
+func EncodeSSHModes(m map[uint8]uint32) []byte {
+	b := make([]byte, 0, len(m)*5+1)
+	for _, op := range SSHModeOpcodesSorted(m) {
+		if op == sshTTYOPEND {
+			continue
+		}
+		b = append(b, op)
+		b = binary.BigEndian.AppendUint32(b, m[op])
+	}
+	return append(b, sshTTYOPEND)
+}
+
+// errSSHModes is returned for encoded terminal modes cut short.
+var errSSHModes = errors.New("truncated SSH terminal modes")
+
+// DecodeSSHModes decodes the encoded terminal modes of an SSH pty-req, see EncodeSSHModes.
+// Decoding stops at TTY_OP_END, anything after it is ignored, or at the first opcode
+// from 160 up which RFC 4254 leaves undefined and without a known argument size.
+func DecodeSSHModes(b []byte) (map[uint8]uint32, error) {
+	m := make(map[uint8]uint32)
+	for len(b) > 0 {
+		op := b[0]
+		if op == sshTTYOPEND || op >= 160 {
+			return m, nil
+		}
+		if len(b) < 5 {
+			return nil, errSSHModes
 		}
+		m[op] = binary.BigEndian.Uint32(b[1:5])
+		b = b[5:]
 	}
+	return m, nil
 }
@@ -5,8 +5,12 @@
 package term
 
 import (
+	"bytes"
+	"reflect"
 	"syscall"
 	"testing"
+
+	"golang.org/x/sys/unix"
 )
 
 // TestSSH tests the Termios<>SSH term attribute conversions.
@@ -39,3 +43,197 @@ func TestSSH(t *testing.T) {
 		t.Errorf("TestSSH failed: %v", err)
 	}
 }
+
+// TestSSHModeOpcodesSorted tests the opcodes come out in order.
+func TestSSHModeOpcodesSorted(t *testing.T) {
+	m := map[uint8]uint32{sshECHO: 1, sshVINTR: 3, sshTTYOPOSPEED: 38400, sshICRNL: 0, sshCS8: 1}
+	want := []uint8{sshVINTR, sshICRNL, sshECHO, sshCS8, sshTTYOPOSPEED}
+	for i := 0; i < 10; i++ {
+		if got := SSHModeOpcodesSorted(m); !reflect.DeepEqual(got, want) {
+			t.Fatalf("SSHModeOpcodesSorted(%v) got: %v want: %v", m, got, want)
+		}
+	}
+	if got := SSHModeOpcodesSorted(nil); len(got) != 0 {
+		t.Errorf("SSHModeOpcodesSorted(nil) got: %v want: []", got)
+	}
+}
+
+// TestEncodeSSHModes tests encoding SSH modes to the wire format.
+func TestEncodeSSHModes(t *testing.T) {
+	tests := []struct {
+		in   map[uint8]uint32
+		want []byte
+	}{
+		{nil, []byte{0}},
+		{map[uint8]uint32{sshTTYOPEND: 5}, []byte{0}},
+		{map[uint8]uint32{sshECHO: 1, sshVINTR: 3}, []byte{1, 0, 0, 0, 3, 53, 0, 0, 0, 1, 0}},
+		{map[uint8]uint32{sshTTYOPISPEED: 38400}, []byte{128, 0, 0, 0x96, 0, 0}},
+	}
+	for _, tst := range tests {
+		if got := EncodeSSHModes(tst.in); !bytes.Equal(got, tst.want) {
+			t.Errorf("EncodeSSHModes(%v) got: %v want: %v", tst.in, got, tst.want)
+		}
+	}
+	var tr Termios
+	tr.Cook()
+	modes := tr.ToSSH()
+	first := EncodeSSHModes(modes)
+	if len(first) != len(modes)*5+1 {
+		t.Errorf("EncodeSSHModes(Cook) got: %d bytes want: %d", len(first), len(modes)*5+1)
+	}
+	for i := 0; i < 10; i++ {
+		if got := EncodeSSHModes(tr.ToSSH()); !bytes.Equal(got, first) {
+			t.Fatalf("EncodeSSHModes(Cook) not deterministic got: %v want: %v", got, first)
+		}
+	}
+}
+
+// TestDecodeSSHModes tests decoding SSH modes from the wire format.
+func TestDecodeSSHModes(t *testing.T) {
+	tests := []struct {
+		in      []byte
+		want    map[uint8]uint32
+		wantErr bool
+	}{
+		{nil, map[uint8]uint32{}, false},
+		{[]byte{0}, map[uint8]uint32{}, false},
+		{[]byte{1, 0, 0, 0, 3, 53, 0, 0, 0, 1, 0}, map[uint8]uint32{sshVINTR: 3, sshECHO: 1}, false},
+		{[]byte{53, 0, 0, 0, 1, 0, 1, 0, 0, 0, 3, 0xff}, map[uint8]uint32{sshECHO: 1}, false},
+		{[]byte{53, 0, 0, 0, 1, 0, 0xde, 0xad}, map[uint8]uint32{sshECHO: 1}, false},
+		{[]byte{53, 0, 0, 0, 1, 160, 1, 0, 0, 0, 3}, map[uint8]uint32{sshECHO: 1}, false},
+		{[]byte{53, 0, 0, 0, 1}, map[uint8]uint32{sshECHO: 1}, false},
+		{[]byte{53, 0, 0}, nil, true},
+	}
+	for _, tst := range tests {
+		got, err := DecodeSSHModes(tst.in)
+		if (err != nil) != tst.wantErr {
+			t.Errorf("DecodeSSHModes(%v) got err: %v want err: %t", tst.in, err, tst.wantErr)
+			continue
+		}
+		if !tst.wantErr && !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("DecodeSSHModes(%v) got: %v want: %v", tst.in, got, tst.want)
+		}
+	}
+	var tr Termios
+	tr.Cook()
+	modes := tr.ToSSH()
+	if got, err := DecodeSSHModes(EncodeSSHModes(modes)); err != nil || !reflect.DeepEqual(got, modes) {
+		t.Errorf("DecodeSSHModes(EncodeSSHModes(%v)) got: %v, %v", modes, got, err)
+	}
+}
+
+// TestFromSSHIgnored tests FromSSH skipping TTY_OP_END and unknown opcodes.
+func TestFromSSHIgnored(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	want := tr
+	tr.FromSSH(map[uint8]uint32{sshTTYOPEND: 1, 42: 0, 99: 1, 159: 0})
+	if tr != want {
+		t.Errorf("FromSSH with ignored opcodes got: %v want: %v", tr, want)
+	}
+}
+
+// TestFromSSHMerge tests FromSSH keeping the modes not in the map.
+func TestFromSSHMerge(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	tr.Iflag |= syscall.IUTF8 // No SSH opcode.
+	tr.Cflag |= syscall.CLOCAL
+	tr.Cc[syscall.VINTR] = 3
+	want := tr
+	want.Lflag &^= syscall.ICANON
+	want.Lflag |= syscall.ECHO
+	want.Cc[syscall.VERASE] = EraseBS
+	tr.FromSSH(map[uint8]uint32{sshICANON: 0, sshECHO: 1, sshVERASE: EraseBS})
+	if tr != want {
+		t.Errorf("FromSSH merge got: %+v want: %+v", tr, want)
+	}
+}
+
+// TestApplySSHSafe tests the unsafe modes are dropped and the speeds clamped.
+func TestApplySSHSafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		modes   map[uint8]uint32
+		modify  func(*Termios)
+		dropped []uint8
+	}{
+		{"safe", map[uint8]uint32{sshECHO: 0, sshVINTR: 3, sshVEOL: '\n'}, func(tr *Termios) {
+			tr.Lflag &^= syscall.ECHO
+			tr.Cc[syscall.VINTR] = 3
+			tr.Cc[syscall.VEOL] = '\n'
+		}, nil},
+		{"unsafe", map[uint8]uint32{sshVDSUSP: 25, sshIUCLC: 1, sshOLCUC: 1, sshCS7: 1, sshPARENB: 1, sshVINTR: '\r', 42: 1, sshVFLUSH: 1},
+			func(*Termios) {}, []uint8{sshVINTR, sshVDSUSP, sshVFLUSH, sshIUCLC, 42, sshOLCUC, sshCS7, sshPARENB}},
+		{"speeds", map[uint8]uint32{sshTTYOPISPEED: 38400, sshTTYOPOSPEED: 40000}, func(tr *Termios) {
+			tr.Ispeed, tr.Ospeed = syscall.B38400, syscall.B38400
+			tr.Cflag = tr.Cflag&^(unix.CBAUD|unix.CIBAUD) | syscall.B38400 | syscall.B38400<<unix.IBSHIFT
+		}, nil},
+		{"out of range", map[uint8]uint32{sshTTYOPISPEED: 10, sshTTYOPOSPEED: 1 << 31}, func(tr *Termios) {
+			tr.Ispeed, tr.Ospeed = syscall.B50, syscall.B4000000
+			tr.Cflag = tr.Cflag&^(unix.CBAUD|unix.CIBAUD) | syscall.B4000000 | syscall.B50<<unix.IBSHIFT
+		}, nil},
+		{"hang up", map[uint8]uint32{sshTTYOPISPEED: 0, sshTTYOPOSPEED: 0}, func(*Termios) {},
+			[]uint8{sshTTYOPISPEED, sshTTYOPOSPEED}},
+	}
+	for _, tst := range tests {
+		var tr Termios
+		tr.Cook()
+		tr.Cflag |= syscall.CS8
+		want := tr
+		tst.modify(&want)
+		if got := tr.ApplySSHSafe(tst.modes); !reflect.DeepEqual(got, tst.dropped) {
+			t.Errorf("ApplySSHSafe %s dropped got: %v want: %v", tst.name, got, tst.dropped)
+		}
+		if tr != want {
+			t.Errorf("ApplySSHSafe %s got: %+v want: %+v", tst.name, tr, want)
+		}
+	}
+	// The kernel takes the speed from Cflag, not Ospeed.
+	tty := rawPTY(t)
+	defer tty.Close()
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.ApplySSHSafe(map[uint8]uint32{sshTTYOPOSPEED: 9600})
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if tr, err = Attr(tty.Slave); err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if got := tr.Cflag & unix.CBAUD; got != syscall.B9600 {
+		t.Errorf("ApplySSHSafe speed after Set got: %#x want: %#x", got, syscall.B9600)
+	}
+}
+
+// TestFromSSHReset tests FromSSHReset starting off the terminal defaults.
+func TestFromSSHReset(t *testing.T) {
+	pty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer pty.Close()
+	fresh, err := Attr(pty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr := fresh
+	tr.Raw()
+	tr.Iflag |= syscall.IUTF8
+	tr.Cc[syscall.VINTR] = 0
+	tr.FromSSHReset(nil)
+	fresh.Iflag &^= syscall.IUTF8
+	if tr != fresh {
+		t.Errorf("FromSSHReset(nil) got: %+v want: %+v", tr, fresh)
+	}
+	tr.Raw()
+	tr.FromSSHReset(map[uint8]uint32{sshECHO: 0, sshVINTR: 7})
+	want := fresh
+	want.Lflag &^= syscall.ECHO
+	want.Cc[syscall.VINTR] = 7
+	if tr != want {
+		t.Errorf("FromSSHReset(ECHO off, VINTR ^G) got: %+v want: %+v", tr, want)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sttyFlag is a single bit flag with its stty name.
+type sttyFlag struct {
+	name  string
+	field func(t *Termios) *uint32
+	bit   uint32
+}
+
+func iflag(t *Termios) *uint32 { return &t.Iflag }
+func oflag(t *Termios) *uint32 { return &t.Oflag }
+func cflag(t *Termios) *uint32 { return &t.Cflag }
+func lflag(t *Termios) *uint32 { return &t.Lflag }
+
+// sttyFlags are the flags known to STTYArgs, in the order stty -a shows them.
+// The delay flags (nl0, cr3 ...) are left out, nothing uses them anymore.
+var sttyFlags = []sttyFlag{
+	{"parenb", cflag, syscall.PARENB},
+	{"parodd", cflag, syscall.PARODD},
+	{"cmspar", cflag, unix.CMSPAR},
+	{"hupcl", cflag, syscall.HUPCL},
+	{"cstopb", cflag, syscall.CSTOPB},
+	{"cread", cflag, syscall.CREAD},
+	{"clocal", cflag, syscall.CLOCAL},
+	{"crtscts", cflag, unix.CRTSCTS},
+	{"ignbrk", iflag, syscall.IGNBRK},
+	{"brkint", iflag, syscall.BRKINT},
+	{"ignpar", iflag, syscall.IGNPAR},
+	{"parmrk", iflag, syscall.PARMRK},
+	{"inpck", iflag, syscall.INPCK},
+	{"istrip", iflag, syscall.ISTRIP},
+	{"inlcr", iflag, syscall.INLCR},
+	{"igncr", iflag, syscall.IGNCR},
+	{"icrnl", iflag, syscall.ICRNL},
+	{"ixon", iflag, syscall.IXON},
+	{"ixoff", iflag, syscall.IXOFF},
+	{"iuclc", iflag, syscall.IUCLC},
+	{"ixany", iflag, syscall.IXANY},
+	{"imaxbel", iflag, syscall.IMAXBEL},
+	{"iutf8", iflag, syscall.IUTF8},
+	{"opost", oflag, syscall.OPOST},
+	{"olcuc", oflag, syscall.OLCUC},
+	{"ocrnl", oflag, syscall.OCRNL},
+	{"onlcr", oflag, syscall.ONLCR},
+	{"onocr", oflag, syscall.ONOCR},
+	{"onlret", oflag, syscall.ONLRET},
+	{"ofill", oflag, syscall.OFILL},
+	{"ofdel", oflag, syscall.OFDEL},
+	{"isig", lflag, syscall.ISIG},
+	{"icanon", lflag, syscall.ICANON},
+	{"iexten", lflag, syscall.IEXTEN},
+	{"echo", lflag, syscall.ECHO},
+	{"echoe", lflag, syscall.ECHOE},
+	{"echok", lflag, syscall.ECHOK},
+	{"echonl", lflag, syscall.ECHONL},
+	{"noflsh", lflag, syscall.NOFLSH},
+	{"xcase", lflag, syscall.XCASE},
+	{"tostop", lflag, syscall.TOSTOP},
+	{"echoprt", lflag, syscall.ECHOPRT},
+	{"echoctl", lflag, syscall.ECHOCTL},
+	{"echoke", lflag, syscall.ECHOKE},
+	{"flusho", lflag, syscall.FLUSHO},
+	{"extproc", lflag, unix.EXTPROC},
+}
+
+// sttyCharSizes are the stty names of the CSIZE values.
+var sttyCharSizes = map[uint32]string{
+	syscall.CS5: "cs5",
+	syscall.CS6: "cs6",
+	syscall.CS7: "cs7",
+	syscall.CS8: "cs8",
+}
+
+// STTYArgs returns the stty arguments turning base into t, eg.
+// ["-echo", "icanon", "intr", "^C"], for running stty from a script or comparing
+// with what the shell tools show. Flags are compared bit by bit as in ApplyDiff,
+// with the same diff, followed by the character size, the control characters, the speed and the window
+// size when they differ. Control characters are in the caret notation stty takes,
+// min and time as numbers. Bits without an stty flag name are left out.
+func (t *Termios) STTYArgs(base Termios) []string {
+	var args []string
+	d := t.diff(base)
+	for _, f := range sttyFlags {
+		switch {
+		case *f.field(&d)&f.bit == 0:
+		case *f.field(t)&f.bit != 0:
+			args = append(args, f.name)
+		default:
+			args = append(args, "-"+f.name)
+		}
+	}
+	if d.Cflag&syscall.CSIZE != 0 {
+		args = append(args, sttyCharSizes[t.Cflag&syscall.CSIZE])
+	}
+	for i := range t.Cc {
+		if d.Cc[i] == 0 {
+			continue
+		}
+		name, ok := ccNames[i]
+		if !ok {
+			continue
+		}
+		args = append(args, name, sttyCC(i, t.Cc[i]))
+	}
+	if s := t.SpeedString(); s != base.SpeedString() && s != "" && s != "custom" {
+		args = append(args, s)
+	}
+	if d.Wz.WsRow != 0 {
+		args = append(args, "rows", strconv.Itoa(int(t.Wz.WsRow)))
+	}
+	if d.Wz.WsCol != 0 {
+		args = append(args, "cols", strconv.Itoa(int(t.Wz.WsCol)))
+	}
+	return args
+}
+
+// sttyCC returns the control character b at index in the notation stty takes.
+func sttyCC(index int, b byte) string {
+	switch {
+	case index == syscall.VMIN || index == syscall.VTIME:
+		return strconv.Itoa(int(b))
+	case b == 0:
+		return "undef"
+	}
+	return FormatCC(b)
+}
+
+// ApplySTTY applies the stty arguments in spec to t, eg. "-echo intr ^C rows 24".
+// Known are the flags of STTYArgs and their negations with "-", cs5 to cs8,
+// the control character names followed by a value in caret notation ("^C", "^?"),
+// a single character or "undef" / "^-", min and time followed by a number, rows and
+// cols (or columns) followed by a number, baud rates like "38400", and raw, -raw, cooked and sane
+// doing what Raw, Cook and Sane do.
+//
+// If anything in spec is not understood t is left as it was and the error lists
+// the arguments not understood.
+func (t *Termios) ApplySTTY(spec string) error {
+	nt := *t
+	var bad []string
+	args := strings.Fields(spec)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if applySTTYFlag(&nt, arg) {
+			continue
+		}
+		if index, ok := ccIndex(arg); ok || arg == "rows" || arg == "cols" || arg == "columns" {
+			if i+1 == len(args) {
+				bad = append(bad, arg)
+				continue
+			}
+			i++
+			if !ok {
+				n, err := strconv.ParseUint(args[i], 10, 16)
+				if err != nil {
+					bad = append(bad, arg+" "+args[i])
+				} else if arg == "rows" {
+					nt.Wz.WsRow = uint16(n)
+				} else {
+					nt.Wz.WsCol = uint16(n)
+				}
+				continue
+			}
+			b, ok := parseSTTYCC(index, args[i])
+			if !ok {
+				bad = append(bad, arg+" "+args[i])
+				continue
+			}
+			nt.Cc[index] = b
+			continue
+		}
+		bad = append(bad, arg)
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("unrecognized stty arguments: %q", bad)
+	}
+	*t = nt
+	return nil
+}
+
+// applySTTYFlag applies a single word stty argument to t.
+// Returns false if arg is not one.
+func applySTTYFlag(t *Termios, arg string) bool {
+	switch arg {
+	case "raw":
+		t.Raw()
+		return true
+	case "-raw", "cooked":
+		t.Cook()
+		return true
+	case "sane":
+		t.Sane()
+		return true
+	}
+	for cs, name := range sttyCharSizes {
+		if arg == name {
+			t.Cflag = t.Cflag&^syscall.CSIZE | cs
+			return true
+		}
+	}
+	for code, name := range speedNames {
+		if arg == name && code != unix.BOTHER {
+			t.Cflag = t.Cflag&^(unix.CBAUD|unix.CBAUDEX) | code
+			t.Ispeed, t.Ospeed = code, code
+			return true
+		}
+	}
+	name, off := strings.CutPrefix(arg, "-")
+	for _, f := range sttyFlags {
+		if f.name != name {
+			continue
+		}
+		if off {
+			*f.field(t) &^= f.bit
+		} else {
+			*f.field(t) |= f.bit
+		}
+		return true
+	}
+	return false
+}
+
+// ccIndex returns the index in Cc of the control character called name by stty.
+func ccIndex(name string) (int, bool) {
+	for i, n := range ccNames {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// parseSTTYCC parses the value v for the control character at index, the inverse of sttyCC.
+// An "M-" prefix sets the high bit as in Caret, eg. "M-^C" for 0x83.
+func parseSTTYCC(index int, v string) (byte, bool) {
+	if index == syscall.VMIN || index == syscall.VTIME {
+		n, err := strconv.ParseUint(v, 10, 8)
+		return byte(n), err == nil
+	}
+	var meta byte
+	if rest, ok := strings.CutPrefix(v, "M-"); ok && rest != "" {
+		meta, v = 0x80, rest
+	}
+	switch {
+	case meta == 0 && (v == "undef" || v == "^-"):
+		return 0, true
+	case v == "^?":
+		return meta | EraseDEL, true
+	case len(v) == 2 && v[0] == '^':
+		return meta | v[1]&0x1f, true
+	case len(v) == 1:
+		return meta | v[0], true
+	}
+	return 0, false
+}
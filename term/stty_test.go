@@ -0,0 +1,129 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestSTTYArgs tests the stty arguments for the differences between two Termios.
+func TestSTTYArgs(t *testing.T) {
+	var base Termios
+	base.Sane()
+	base.Lflag |= syscall.ECHO | syscall.ICANON
+	base.Cflag |= syscall.CS8 | syscall.B38400
+	base.Cc[syscall.VINTR] = 3
+	tests := []struct {
+		name   string
+		modify func(*Termios)
+		want   []string
+	}{
+		{"same", func(*Termios) {}, nil},
+		{"flags", func(tr *Termios) {
+			tr.Lflag &^= syscall.ECHO
+			tr.Iflag |= syscall.IUTF8
+		}, []string{"iutf8", "-echo"}},
+		{"cc", func(tr *Termios) {
+			tr.Cc[syscall.VINTR] = 0
+			tr.Cc[syscall.VERASE] = EraseDEL
+			tr.Cc[syscall.VMIN] = 1
+		}, []string{"intr", "undef", "erase", "^?", "min", "1"}},
+		{"raw", (*Termios).Raw, []string{"-brkint", "-icrnl", "-opost", "-icanon", "-echo", "min", "1"}},
+		{"size", func(tr *Termios) {
+			tr.Cflag = tr.Cflag&^(syscall.CSIZE|unix.CBAUD) | syscall.CS7 | syscall.B9600
+			tr.Wz = Winsize{WsRow: 24, WsCol: 80}
+		}, []string{"cs7", "9600", "rows", "24", "cols", "80"}},
+	}
+	for _, tst := range tests {
+		tr := base
+		tst.modify(&tr)
+		if got := tr.STTYArgs(base); !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("STTYArgs %s got: %q want: %q", tst.name, got, tst.want)
+		}
+	}
+}
+
+// TestApplySTTY tests applying stty arguments.
+func TestApplySTTY(t *testing.T) {
+	var base Termios
+	base.Sane()
+	base.Lflag |= syscall.ECHO | syscall.ICANON
+	base.Cflag |= syscall.CS8 | syscall.B38400
+	tests := []struct {
+		spec   string
+		modify func(*Termios)
+	}{
+		{"", func(*Termios) {}},
+		{"-echo icanon iutf8", func(tr *Termios) {
+			tr.Lflag &^= syscall.ECHO
+			tr.Iflag |= syscall.IUTF8
+		}},
+		{"intr ^C erase ^? kill ^- eof d min 1 time 5", func(tr *Termios) {
+			tr.Cc[syscall.VINTR] = 3
+			tr.Cc[syscall.VERASE] = EraseDEL
+			tr.Cc[syscall.VEOF] = 'd'
+			tr.Cc[syscall.VMIN] = 1
+			tr.Cc[syscall.VTIME] = 5
+		}},
+		{"eol M-^C eol2 M-a", func(tr *Termios) {
+			tr.Cc[syscall.VEOL] = 0x83
+			tr.Cc[syscall.VEOL2] = 0xe1
+		}},
+		{"raw", (*Termios).Raw},
+		{"raw -raw", func(tr *Termios) {
+			tr.Raw()
+			tr.Cook()
+		}},
+		{"cs7 9600 rows 24 cols 80", func(tr *Termios) {
+			tr.Cflag = tr.Cflag&^(syscall.CSIZE|unix.CBAUD) | syscall.CS7 | syscall.B9600
+			tr.Ispeed, tr.Ospeed = syscall.B9600, syscall.B9600
+			tr.Wz = Winsize{WsRow: 24, WsCol: 80}
+		}},
+	}
+	for _, tst := range tests {
+		got, want := base, base
+		tst.modify(&want)
+		if err := got.ApplySTTY(tst.spec); err != nil {
+			t.Errorf("ApplySTTY(%q) failed: %v", tst.spec, err)
+		}
+		if got != want {
+			t.Errorf("ApplySTTY(%q) got: %+v want: %+v", tst.spec, got, want)
+		}
+	}
+	got := base
+	err := got.ApplySTTY("-echo bogus intr ^CC eof M-undef rows")
+	if err == nil || !strings.Contains(err.Error(), `["bogus" "intr ^CC" "eof M-undef" "rows"]`) {
+		t.Errorf("ApplySTTY with unknown arguments got: %v want: bogus, intr ^CC, eof M-undef and rows listed", err)
+	}
+	if got != base {
+		t.Errorf("ApplySTTY with unknown arguments changed t got: %+v want: %+v", got, base)
+	}
+}
+
+// TestSTTYRoundTrip tests applying the STTYArgs to the base gives back the Termios.
+func TestSTTYRoundTrip(t *testing.T) {
+	var base, tr Termios
+	base.Sane()
+	base.Cflag |= syscall.CS8
+	tr = base
+	tr.Raw()
+	tr.Iflag |= syscall.IUTF8
+	tr.Cc[syscall.VERASE] = EraseBS
+	tr.Cc[syscall.VEOL] = 0x83
+	tr.Cc[syscall.VEOL2] = 0x80 | EraseDEL
+	tr.Cc[syscall.VDISCARD] = 0xe1
+	got := base
+	if err := got.ApplySTTY(strings.Join(tr.STTYArgs(base), " ")); err != nil {
+		t.Fatalf("ApplySTTY failed: %v", err)
+	}
+	if got != tr {
+		t.Errorf("ApplySTTY(STTYArgs) got: %+v want: %+v", got, tr)
+	}
+}
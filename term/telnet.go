@@ -0,0 +1,66 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"io"
+	"syscall"
+)
+
+// TelnetNVT sets t up for the Slave of a PTY bridged to a telnet client, following
+// the newline conventions of the RFC 854 Network Virtual Terminal (NVT), with the
+// server echoing (WILL ECHO):
+//
+//	Iflag	ICRNL on, INLCR, IGNCR, ISTRIP and IUCLC off
+//	Oflag	OPOST and ONLCR on, OCRNL, ONOCR and ONLRET off, see SetOutputNewline
+//	Cflag	CS8, no parity
+//	Lflag	ICANON, ISIG, IEXTEN, ECHO, ECHOE, ECHOK and ECHOCTL on
+//
+// On output "\n" goes out as the NVT end of line "\r\n". On input the client ends
+// lines with "\r\n" or "\r\x00", which a terminal can't take as is, ICRNL makes the
+// "\r" a newline but leaves what follows. Feed the client's input through NewNVTReader,
+// which drops that byte, for Enter to end exactly one line.
+func (t *Termios) TelnetNVT() {
+	t.Iflag |= syscall.ICRNL
+	t.Iflag &^= syscall.INLCR | syscall.IGNCR | syscall.ISTRIP | syscall.IUCLC
+	t.SetOutputNewline(OutputCRLF)
+	t.Cflag &^= syscall.CSIZE | syscall.PARENB
+	t.Cflag |= syscall.CS8
+	t.Lflag |= syscall.ICANON | syscall.ISIG | syscall.IEXTEN | syscall.ECHO | syscall.ECHOE |
+		syscall.ECHOK | syscall.ECHOCTL
+}
+
+// NewNVTReader returns a Reader turning the NVT line endings read from r, "\r\n" and
+// "\r\x00", into a bare "\r", for copying a telnet client's input to a PTY set up
+// with TelnetNVT. Telnet commands (IAC) are not handled, r has to have them removed.
+func NewNVTReader(r io.Reader) io.Reader {
+	return &nvtReader{r: r}
+}
+
+// nvtReader drops the byte after a CR when it's a LF or NUL.
+type nvtReader struct {
+	r  io.Reader
+	cr bool // cr the last byte read was a CR
+}
+
+// Read implements the io.Reader interface.
+func (nr *nvtReader) Read(b []byte) (int, error) {
+	for {
+		n, err := nr.r.Read(b)
+		w := 0
+		for _, c := range b[:n] {
+			if nr.cr && (c == '\n' || c == 0) {
+				nr.cr = false
+				continue
+			}
+			nr.cr = c == '\r'
+			b[w] = c
+			w++
+		}
+		if w > 0 || err != nil || n == 0 {
+			return w, err
+		}
+	}
+}
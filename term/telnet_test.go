@@ -0,0 +1,75 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// TestTelnetNVT tests a line from a telnet client reads as one line off the Slave.
+func TestTelnetNVT(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Raw()
+	tr.Iflag |= syscall.INLCR | syscall.IGNCR
+	tr.TelnetNVT()
+	if tr.Iflag&(syscall.INLCR|syscall.IGNCR) != 0 || tr.Iflag&syscall.ICRNL == 0 ||
+		tr.Oflag&(syscall.OPOST|syscall.ONLCR) != syscall.OPOST|syscall.ONLCR ||
+		tr.Lflag&(syscall.ICANON|syscall.ECHO) != syscall.ICANON|syscall.ECHO {
+		t.Errorf("TelnetNVT got: %+v want: ICRNL, OPOST, ONLCR, ICANON and ECHO set", tr)
+	}
+	tr.Lflag &^= syscall.ECHO
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := io.Copy(tty.Master, NewNVTReader(strings.NewReader("ls\r\nwho\r\x00"))); err != nil {
+		t.Fatalf("Copying to Master failed: %v", err)
+	}
+	tty.Slave.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 64)
+	for _, want := range []string{"ls\n", "who\n"} {
+		n, err := tty.Slave.Read(b)
+		if err != nil || string(b[:n]) != want {
+			t.Errorf("Slave got: %q, %v want: %q, <nil>", b[:n], err, want)
+		}
+	}
+	tty.Slave.Write([]byte("out\n"))
+	n, err := tty.Master.Read(b)
+	if err != nil || string(b[:n]) != "out\r\n" {
+		t.Errorf("Master got: %q, %v want: %q, <nil>", b[:n], err, "out\r\n")
+	}
+}
+
+// TestNVTReader tests the NVT line endings are turned into a CR.
+func TestNVTReader(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"ls\r\n", "ls\r"},
+		{"a\r\x00b\r", "a\rb\r"},
+		{"\r\r\n\n\x00", "\r\r\n\x00"},
+		{"plain\n", "plain\n"},
+	}
+	for _, tst := range tests {
+		for _, r := range []io.Reader{strings.NewReader(tst.in), iotest.OneByteReader(strings.NewReader(tst.in))} {
+			got, err := io.ReadAll(NewNVTReader(r))
+			if err != nil || string(got) != tst.want {
+				t.Errorf("NVTReader(%q) got: %q, %v want: %q, <nil>", tst.in, got, err, tst.want)
+			}
+		}
+	}
+}
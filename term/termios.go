@@ -22,9 +22,15 @@ package term
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -34,6 +40,20 @@ const (
 	tNCCS = 32 // tNCCS    Termios CC size
 )
 
+// ErrTimeout is returned by the reading functions when no data arrived in time.
+var ErrTimeout = errors.New("timed out waiting for input")
+
+// ErrPTYClosed is returned, wrapping the error of the failed operation,
+// when using a PTY after Close.
+var ErrPTYClosed = errors.New("pty closed")
+
+// Flags cleared by Raw.
+const (
+	rawIflag = syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	rawOflag = syscall.OPOST
+	rawLflag = syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+)
+
 // Termios merge of the C Terminal and Kernel termios structs.
 type Termios struct {
 	Iflag  uint32      // Iflag Handles the different Input modes
@@ -70,22 +90,112 @@ type Winsize struct {
 type PTY struct {
 	Master *os.File // Master The Master part of the PTY
 	Slave  *os.File // Slave The Slave part of the PTY
+
+	// RedactPatterns are replaced by "***" in the RecordTimed transcripts, eg. secrets
+	// echoed back. Matched a line at a time, see RecordTimed.
+	RedactPatterns []*regexp.Regexp
+
+	ptsDir string // ptsDir devpts instance the Slave lives in, "" for /dev/pts
+
+	mu      sync.Mutex    // mu guards the Proxy output state below
+	out     io.Writer     // out where the running Proxy writes the Master output
+	paused  bool          // paused Master output is held back from out, see Pause
+	drop    bool          // drop Master output while paused instead of buffering it
+	pending *ring         // pending Master output buffered while paused
+	pauses  int           // pauses counts the Pause calls, for Resume to notice one
+	outMu   sync.Mutex    // outMu serializes the writes to out, held without mu
+	scroll  *ring         // scroll the last Master output, see SetScrollback
+	closed  bool          // closed Close has been called
+	log     Logger        // log gets the lifecycle events, see SetLogger
+	proxied chan struct{} // proxied closed when the running Proxy returns, nil when none is
 }
 
 // Raw Sets terminal t to raw mode.
 // This gives that the terminal will do the absolut minimal of processing, pretty much send everything through.
 // This is normally what Shells and such want since they have their own readline and movement code.
 func (t *Termios) Raw() {
-	t.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	t.Iflag &^= rawIflag
 	// t.Iflag &^= BRKINT | ISTRIP | ICRNL | IXON // Stevens RAW
-	t.Oflag &^= syscall.OPOST
-	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	t.Oflag &^= rawOflag
+	t.Lflag &^= rawLflag
 	t.Cflag &^= syscall.CSIZE | syscall.PARENB
 	t.Cflag |= syscall.CS8
 	t.Cc[syscall.VMIN] = 1
 	t.Cc[syscall.VTIME] = 0
 }
 
+// RawWithSignals Sets terminal t to raw mode like Raw but with ISIG on.
+// The INTR (^C), QUIT (^\) and SUSP (^Z) characters keep generating signals so the
+// program can still be killed or suspended from the keyboard, the tradeoff being that
+// those keys are never seen by the program itself.
+func (t *Termios) RawWithSignals() {
+	t.Raw()
+	t.Lflag |= syscall.ISIG
+}
+
+// OutputNLMode how line endings are written to the terminal, see SetOutputNewline.
+type OutputNLMode int
+
+// Output newline modes.
+const (
+	OutputLF   OutputNLMode = iota // OutputLF   "\n" and "\r" are written as is
+	OutputCRLF                     // OutputCRLF "\n" is written as "\r\n", the normal terminal setting
+	OutputCR                       // OutputCR   "\r" is written as "\n", for output ending lines with a bare CR
+)
+
+// Output newline mapping flags, the ones SetOutputNewline sets or clears.
+const outputNLFlags = syscall.ONLCR | syscall.OCRNL | syscall.ONOCR | syscall.ONLRET
+
+// SetOutputNewline sets the output newline mapping of t to mode, setting the Oflag
+// bits the mode needs and clearing the other ones that conflict with it:
+//
+//	OutputLF	ONLCR, OCRNL, ONOCR and ONLRET off
+//	OutputCRLF	OPOST and ONLCR on, OCRNL, ONOCR and ONLRET off
+//	OutputCR	OPOST and OCRNL on, ONLCR, ONOCR and ONLRET off
+//
+// Having both ONLCR and OCRNL on turns "\r\n" into "\n\n", doubling newlines, and
+// ONLCR off on a terminal expecting it leaves every line starting where the last one
+// ended, staircasing. OPOST is left as it is for OutputLF.
+func (t *Termios) SetOutputNewline(mode OutputNLMode) {
+	t.Oflag &^= outputNLFlags
+	switch mode {
+	case OutputCRLF:
+		t.Oflag |= syscall.OPOST | syscall.ONLCR
+	case OutputCR:
+		t.Oflag |= syscall.OPOST | syscall.OCRNL
+	}
+}
+
+// NoEchoNoSignal Sets terminal t up for typing a secret, eg. a password or passphrase.
+// ECHO and ECHONL are cleared so neither the typed characters nor the newline ending
+// them are shown, and ISIG is cleared so INTR (^C), QUIT (^\) and SUSP (^Z) come in as
+// characters instead of signals and can't leave the process dying or stopped with the
+// echo off. ICANON is left on so the line editing with erase and kill keeps working.
+// Nothing else is changed, see EchoAndSignals for turning echo and signals back on.
+func (t *Termios) NoEchoNoSignal() {
+	t.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ISIG
+}
+
+// EchoAndSignals turns ECHO and ISIG back on after NoEchoNoSignal.
+// ECHONL is normally off in canonical mode and is left off.
+func (t *Termios) EchoAndSignals() {
+	t.Lflag |= syscall.ECHO | syscall.ISIG
+}
+
+// DisableFlowKeys turns off XON/XOFF flow control, clearing IXON and IXOFF, so ^S and ^Q
+// reach the application instead of freezing and unfreezing the terminal output.
+// Nothing else is changed, the terminal stays in whatever mode it was.
+func (t *Termios) DisableFlowKeys() {
+	t.Iflag &^= syscall.IXON | syscall.IXOFF
+}
+
+// EnableFlowKeys turns ^S / ^Q output flow control, IXON, back on.
+// IXOFF, the terminal sending ^S / ^Q when its input queue fills up, is left off
+// as that's the normal setting.
+func (t *Termios) EnableFlowKeys() {
+	t.Iflag |= syscall.IXON
+}
+
 // Cook Set the Terminal to Cooked mode.
 // In this mode the Terminal process the information before sending it on to the application.
 func (t *Termios) Cook() {
@@ -104,6 +214,275 @@ func (t *Termios) Sane() {
 	t.Cflag |= syscall.CREAD
 }
 
+// Terminal reset escape sequence.
+// RIS (Reset to Initial State) makes the terminal forget all its modes, charsets,
+// scrolling regions, colors etc. clears the screen and homes the cursor.
+const risReset = "\033c"
+
+// ResetTerminal tries to bring a scrambled terminal, eg. after a crashed curses application,
+// back to a usable state. Similar to what the shell command "reset" does.
+//
+// The attributes of f are set to Sane() with canonical mode, signals and echo turned on, then
+// the RIS, "\033c", escape sequence is written to f.
+func ResetTerminal(f *os.File) error {
+	t, err := Attr(f)
+	if err != nil {
+		return err
+	}
+	t.Sane()
+	t.Lflag |= syscall.ICANON | syscall.ISIG | syscall.IEXTEN | syscall.ECHO | syscall.ECHOE | syscall.ECHOK
+	if err := t.Set(f); err != nil {
+		return err
+	}
+	_, err = f.Write([]byte(risReset))
+	return err
+}
+
+// IsRaw returns true if all the flags cleared by Raw are cleared in t.
+func (t *Termios) IsRaw() bool {
+	return t.Iflag&rawIflag == 0 && t.Oflag&rawOflag == 0 && t.Lflag&rawLflag == 0
+}
+
+// IsCanonical returns true if t is in canonical (line by line) input mode.
+func (t *Termios) IsCanonical() bool {
+	return t.Lflag&syscall.ICANON != 0
+}
+
+// EchoEnabled returns true if input characters are echoed back.
+func (t *Termios) EchoEnabled() bool {
+	return t.Lflag&syscall.ECHO != 0
+}
+
+// SignalsEnabled returns true if the INTR, QUIT and SUSP characters generate signals.
+func (t *Termios) SignalsEnabled() bool {
+	return t.Lflag&syscall.ISIG != 0
+}
+
+// WouldChange returns true if applying modify to t would change any of its attributes.
+// t itself is left untouched.
+func (t *Termios) WouldChange(modify func(*Termios)) bool {
+	nt := *t
+	modify(&nt)
+	return nt != *t
+}
+
+// NeedsRaw returns true if calling Raw on t would change anything.
+// Handy to skip a redundant Set when the terminal already is in raw mode.
+func (t *Termios) NeedsRaw() bool {
+	return t.WouldChange((*Termios).Raw)
+}
+
+// SetUTF8 toggles the Linux specific IUTF8 input flag.
+// With IUTF8 set the kernel line editor in canonical mode knows input is UTF-8
+// and erases a whole multibyte character on backspace instead of a single byte
+// of it, which otherwise leaves garbage on the line.
+func (t *Termios) SetUTF8(on bool) {
+	if on {
+		t.Iflag |= syscall.IUTF8
+	} else {
+		t.Iflag &^= syscall.IUTF8
+	}
+}
+
+// SetNoFlush toggles the NOFLSH local flag.
+// Normally when INTR (^C), QUIT (^\) or SUSP (^Z) generate a signal the input and output
+// queues are flushed, throwing away any typeahead. With NOFLSH set the queues are kept,
+// so what was typed ahead of a ^C is still there to read after handling the signal.
+// Only matters with ISIG on, without it no signals are generated and nothing is flushed.
+func (t *Termios) SetNoFlush(on bool) {
+	setFlag(&t.Lflag, syscall.NOFLSH, on)
+}
+
+// SetStopOnBackgroundWrite toggles the TOSTOP local flag.
+// With TOSTOP set a background process group writing to the terminal gets SIGTTOU,
+// stopping it until it's brought to the foreground, instead of its output getting
+// mixed into the foreground job's. Shells leave it off by default.
+func (t *Termios) SetStopOnBackgroundWrite(on bool) {
+	setFlag(&t.Lflag, syscall.TOSTOP, on)
+}
+
+// LineEditChars the line editing characters of canonical mode, see SetLineEditing.
+type LineEditChars struct {
+	Erase     byte // Erase Cc[VERASE], erasing a character
+	Kill      byte // Kill Cc[VKILL], erasing the line
+	WordErase byte // WordErase Cc[VWERASE], erasing a word
+}
+
+// SetLineEditing turns the kernel line editing in canonical mode off or back on,
+// leaving the line buffering as is, and returns the characters as they were.
+// Off disables the erase (^?), kill (^U) and word erase (^W) characters so they're
+// read like any other character. On sets those that are disabled to the
+// conventional ^?, ^U and ^W; to restore the user's own pass what off returned
+// to SetLineEditChars instead.
+func (t *Termios) SetLineEditing(on bool) LineEditChars {
+	prev := t.LineEditChars()
+	if !on {
+		t.SetLineEditChars(LineEditChars{})
+		return prev
+	}
+	c := prev
+	if c.Erase == 0 {
+		c.Erase = EraseDEL
+	}
+	if c.Kill == 0 {
+		c.Kill = 'U' & 0x1f
+	}
+	if c.WordErase == 0 {
+		c.WordErase = 'W' & 0x1f
+	}
+	t.SetLineEditChars(c)
+	return prev
+}
+
+// LineEditChars returns the line editing characters of t.
+func (t *Termios) LineEditChars() LineEditChars {
+	return LineEditChars{Erase: t.Cc[syscall.VERASE], Kill: t.Cc[syscall.VKILL], WordErase: t.Cc[syscall.VWERASE]}
+}
+
+// SetLineEditChars sets the line editing characters of t to c, eg. putting back
+// those SetLineEditing(false) returned.
+func (t *Termios) SetLineEditChars(c LineEditChars) {
+	t.Cc[syscall.VERASE], t.Cc[syscall.VKILL], t.Cc[syscall.VWERASE] = c.Erase, c.Kill, c.WordErase
+}
+
+// SetEchoErase toggles ECHOE. With it set, and ECHO, erasing a character rubs it out
+// on the screen with backspace, space, backspace. Without it the erase character is
+// echoed instead, eg. showing up as ^? with ECHOCTL, and the erased character stays.
+func (t *Termios) SetEchoErase(on bool) {
+	setFlag(&t.Lflag, syscall.ECHOE, on)
+}
+
+// SetEchoKill toggles ECHOK. With it set the kill character is followed by a newline,
+// the line killed stays on the screen and typing goes on below it. ECHOKE takes
+// precedence when set too.
+func (t *Termios) SetEchoKill(on bool) {
+	setFlag(&t.Lflag, syscall.ECHOK, on)
+}
+
+// SetEchoKillErase toggles ECHOKE. With it set the kill character rubs out the
+// whole line on the screen, the way it's shown after erasing every character.
+func (t *Termios) SetEchoKillErase(on bool) {
+	setFlag(&t.Lflag, syscall.ECHOKE, on)
+}
+
+// setFlag sets or clears bit in flags.
+func setFlag(flags *uint32, bit uint32, on bool) {
+	if on {
+		*flags |= bit
+	} else {
+		*flags &^= bit
+	}
+}
+
+// Erase characters sent by the backspace key.
+const (
+	EraseBS  = 0x08 // EraseBS  ^H, sent by some terminals and older clients
+	EraseDEL = 0x7f // EraseDEL ^?, the Linux console and xterm default
+)
+
+// SetErase sets the erase character, Cc[VERASE], to b.
+//
+// Terminals disagree on what the backspace key sends, ^H (EraseBS) or ^? (EraseDEL).
+// If VERASE does not match what the client sends longer lines can't be corrected and
+// a literal ^H or ^? gets inserted instead. This typically shows when bridging an SSH
+// client to a local PTY, the erase char the client announced via FromSSH should be kept.
+func (t *Termios) SetErase(b byte) {
+	t.Cc[syscall.VERASE] = b
+}
+
+// NormalizeErase makes sure the erase character is one of the ones backspace
+// keys actually send. ^H and ^? are left alone, anything else (eg. disabled) is
+// set to the conventional ^?.
+func (t *Termios) NormalizeErase() {
+	switch t.Cc[syscall.VERASE] {
+	case EraseBS, EraseDEL:
+	default:
+		t.SetErase(EraseDEL)
+	}
+}
+
+// ReconcileErase fixes the attributes that commonly leave backspace, and Enter, not
+// working when the modes come from elsewhere, eg. an SSH client's applied with FromSSH:
+//
+//   - An erase character, VERASE, no backspace key sends, eg. a client sending it
+//     disabled, is set to ^?, see NormalizeErase.
+//   - ECHOE is set when echoing in canonical mode, the erased character is rubbed out
+//     on the screen instead of the erase character being echoed, eg. as ^? with ECHOCTL.
+//   - ICRNL is set in canonical mode when neither VEOL nor VEOL2 is '\r', clients
+//     leaving it out would otherwise have an Enter key ending no line.
+//
+// It can't tell whether the client's ^H or ^? is the one its keyboard actually sends,
+// when that's known use SetErase.
+func (t *Termios) ReconcileErase() {
+	t.NormalizeErase()
+	if t.Lflag&syscall.ICANON == 0 {
+		return
+	}
+	if t.Lflag&syscall.ECHO != 0 {
+		t.SetEchoErase(true)
+	}
+	if t.Cc[syscall.VEOL] != '\r' && t.Cc[syscall.VEOL2] != '\r' {
+		t.Iflag |= syscall.ICRNL
+	}
+}
+
+// Names of the control characters, as used by stty.
+var ccNames = map[int]string{
+	syscall.VINTR:    "intr",
+	syscall.VQUIT:    "quit",
+	syscall.VERASE:   "erase",
+	syscall.VKILL:    "kill",
+	syscall.VEOF:     "eof",
+	syscall.VTIME:    "time",
+	syscall.VMIN:     "min",
+	syscall.VSWTC:    "swtch",
+	syscall.VSTART:   "start",
+	syscall.VSTOP:    "stop",
+	syscall.VSUSP:    "susp",
+	syscall.VEOL:     "eol",
+	syscall.VREPRINT: "reprint",
+	syscall.VDISCARD: "discard",
+	syscall.VWERASE:  "werase",
+	syscall.VLNEXT:   "lnext",
+	syscall.VEOL2:    "eol2",
+}
+
+// CCName returns the stty name of the control character at index in Termios.Cc,
+// eg. "intr" for syscall.VINTR. Unused indexes are returned as "cc<index>".
+func CCName(index int) string {
+	if name, ok := ccNames[index]; ok {
+		return name
+	}
+	return "cc" + strconv.Itoa(index)
+}
+
+// Caret returns b in caret notation, "^@" to "^_" for the control characters 0 to 0x1f,
+// "^?" for 0x7f and b itself for anything else.
+// Bytes with the high bit set, meta, get an "M-" prefix, eg. "M-^C" for 0x83.
+func Caret(b byte) string {
+	var meta string
+	if b >= 0x80 {
+		meta, b = "M-", b-0x80
+	}
+	switch {
+	case b < 0x20:
+		return meta + "^" + string(rune(b+'@'))
+	case b == EraseDEL:
+		return meta + "^?"
+	}
+	return meta + string(rune(b))
+}
+
+// FormatCC returns the control character b readable the way stty shows it,
+// eg. "^C" for 0x03, "^?" for 0x7f and "<undef>" for 0 which turns it off.
+// Anything but 0 is shown in caret notation, see Caret.
+func FormatCC(b byte) string {
+	if b == 0 {
+		return "<undef>"
+	}
+	return Caret(b)
+}
+
 // Set Sets terminal t attributes on file.
 func (t *Termios) Set(file *os.File) error {
 	fd := file.Fd()
@@ -114,26 +493,188 @@ func (t *Termios) Set(file *os.File) error {
 	return nil
 }
 
+// ApplyDiff sets the attributes where t differs from from on file, leaving all other
+// attributes as they currently are on file. For when several layers manage the same
+// terminal, eg. keeping flags another process changed since from was read.
+//
+// Flags are compared bit by bit and control characters one by one.
+// This is best-effort, the current attributes are read and then set so a change
+// done by someone else in between the two is lost.
+func (t *Termios) ApplyDiff(file *os.File, from Termios) error {
+	cur, err := Attr(file)
+	if err != nil {
+		return err
+	}
+	d := t.diff(from)
+	cur.Iflag = mergeBits(cur.Iflag, t.Iflag, d.Iflag)
+	cur.Oflag = mergeBits(cur.Oflag, t.Oflag, d.Oflag)
+	cur.Cflag = mergeBits(cur.Cflag, t.Cflag, d.Cflag)
+	cur.Lflag = mergeBits(cur.Lflag, t.Lflag, d.Lflag)
+	if d.Line != 0 {
+		cur.Line = t.Line
+	}
+	for i := range t.Cc {
+		if d.Cc[i] != 0 {
+			cur.Cc[i] = t.Cc[i]
+		}
+	}
+	if d.Ispeed != 0 {
+		cur.Ispeed = t.Ispeed
+	}
+	if d.Ospeed != 0 {
+		cur.Ospeed = t.Ospeed
+	}
+	return cur.Set(file)
+}
+
+// diff returns what differs between t and from, each field of the result is t's
+// XORed with from's so it's non-zero, or has the bits set, where they differ.
+// Shared by ApplyDiff and STTYArgs.
+func (t *Termios) diff(from Termios) Termios {
+	d := Termios{
+		Iflag:  t.Iflag ^ from.Iflag,
+		Oflag:  t.Oflag ^ from.Oflag,
+		Cflag:  t.Cflag ^ from.Cflag,
+		Lflag:  t.Lflag ^ from.Lflag,
+		Line:   t.Line ^ from.Line,
+		Ispeed: t.Ispeed ^ from.Ispeed,
+		Ospeed: t.Ospeed ^ from.Ospeed,
+		Wz: Winsize{
+			WsRow:    t.Wz.WsRow ^ from.Wz.WsRow,
+			WsCol:    t.Wz.WsCol ^ from.Wz.WsCol,
+			WsXpixel: t.Wz.WsXpixel ^ from.Wz.WsXpixel,
+			WsYpixel: t.Wz.WsYpixel ^ from.Wz.WsYpixel,
+		},
+	}
+	for i := range t.Cc {
+		d.Cc[i] = t.Cc[i] ^ from.Cc[i]
+	}
+	return d
+}
+
+// mergeBits returns cur with the bits set in diff set as in want.
+func mergeBits(cur, want, diff uint32) uint32 {
+	return cur&^diff | want&diff
+}
+
 // Attr Gets (terminal related) attributes from file.
 func Attr(file *os.File) (Termios, error) {
 	var t Termios
+	err := AttrInto(file, &t)
+	return t, err
+}
+
+// AttrInto gets the attributes from file like Attr, but into t instead of returning
+// a copy. Meant for loops reconfiguring the terminal often, t is only changed when
+// there's no error. Wz is not part of the attributes and is left alone, see Winsz.
+func AttrInto(file *os.File, t *Termios) error {
 	fd := file.Fd()
-	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(&t)))
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(t)))
 	if errno != 0 {
-		return t, errno
+		return errno
 	}
 	t.Ispeed &= unix.CBAUD | unix.CBAUDEX
 	t.Ospeed &= unix.CBAUD | unix.CBAUDEX
-	return t, nil
+	return nil
+}
+
+// Baud rates of the B* speed codes, all the values of CBAUD | CBAUDEX.
+var speedNames = map[uint32]string{
+	unix.B0:       "0",
+	unix.B50:      "50",
+	unix.B75:      "75",
+	unix.B110:     "110",
+	unix.B134:     "134",
+	unix.B150:     "150",
+	unix.B200:     "200",
+	unix.B300:     "300",
+	unix.B600:     "600",
+	unix.B1200:    "1200",
+	unix.B1800:    "1800",
+	unix.B2400:    "2400",
+	unix.B4800:    "4800",
+	unix.B9600:    "9600",
+	unix.B19200:   "19200",
+	unix.B38400:   "38400",
+	unix.B57600:   "57600",
+	unix.B115200:  "115200",
+	unix.B230400:  "230400",
+	unix.B460800:  "460800",
+	unix.B500000:  "500000",
+	unix.B576000:  "576000",
+	unix.B921600:  "921600",
+	unix.B1000000: "1000000",
+	unix.B1152000: "1152000",
+	unix.B1500000: "1500000",
+	unix.B2000000: "2000000",
+	unix.B2500000: "2500000",
+	unix.B3000000: "3000000",
+	unix.B3500000: "3500000",
+	unix.B4000000: "4000000",
+	unix.BOTHER:   "custom",
+}
+
+// SpeedString returns the output speed of t as its baud rate, eg. "38400" for B38400,
+// or "custom" for BOTHER, a rate not in the B* codes.
+// The speed is taken from Ospeed, as masked to the B* bits by Attr, or when that's 0
+// from the speed bits of Cflag, which is where the kernel keeps it.
+func (t *Termios) SpeedString() string {
+	code := t.Ospeed & (unix.CBAUD | unix.CBAUDEX)
+	if code == 0 {
+		code = t.Cflag & (unix.CBAUD | unix.CBAUDEX)
+	}
+	return speedNames[code]
 }
 
 // Isatty returns true if file is a tty.
 func Isatty(file *os.File) bool {
-	_, err := Attr(file)
-	return err == nil
+	return isatty(file.Fd())
+}
+
+// Cached Isatty results for the standard streams.
+var (
+	stdinTTY  = ttyOnce(os.Stdin)
+	stdoutTTY = ttyOnce(os.Stdout)
+	stderrTTY = ttyOnce(os.Stderr)
+)
+
+// ttyOnce returns a function checking Isatty(f) the first time it's called only.
+func ttyOnce(f *os.File) func() bool {
+	var once sync.Once
+	var tty bool
+	return func() bool {
+		once.Do(func() { tty = Isatty(f) })
+		return tty
+	}
+}
+
+// Stdin returns true if os.Stdin is a tty, false eg. when it's a pipe.
+// Checked once, later calls return the cached result.
+func Stdin() bool {
+	return stdinTTY()
+}
+
+// Stdout returns true if os.Stdout is a tty. Checked once like Stdin.
+func Stdout() bool {
+	return stdoutTTY()
+}
+
+// Stderr returns true if os.Stderr is a tty. Checked once like Stdin.
+func Stderr() bool {
+	return stderrTTY()
+}
+
+// isatty checks if fd is a tty without going through Attr.
+// Only asks for the kernel termios struct, nothing is masked or copied out.
+func isatty(fd uintptr) bool {
+	var t unix.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(&t)))
+	return errno == 0
 }
 
 // GetPass reads password from a TTY with no echo.
+// Reads interrupted by a signal, EINTR, are retried. On any other read error
+// the password read so far is cleared from pbuf and the error returned.
 func GetPass(prompt string, f *os.File, pbuf []byte) ([]byte, error) {
 	t, err := Attr(f)
 	if err != nil {
@@ -145,26 +686,53 @@ func GetPass(prompt string, f *os.File, pbuf []byte) ([]byte, error) {
 	if err := noecho.Set(f); err != nil {
 		return nil, err
 	}
-	b := make([]byte, 1, 1)
-	i := 0
 	if _, err := f.Write([]byte(prompt)); err != nil {
 		return nil, err
 	}
-	for ; i < len(pbuf); i++ {
-		if _, err := f.Read(b); err != nil {
-			b[0] = 0
-			clearbuf(pbuf[:i+1])
+	return readPass(f, pbuf)
+}
+
+// maxEmptyReads how many reads in a row readPass takes returning nothing and no
+// error before giving up with io.ErrNoProgress, as bufio does.
+const maxEmptyReads = 100
+
+// readPass reads a line into pbuf for GetPass.
+func readPass(r io.Reader, pbuf []byte) ([]byte, error) {
+	b := make([]byte, 1, 1)
+	i, empty := 0, 0
+	for i < len(pbuf) {
+		n, err := r.Read(b)
+		if errors.Is(err, syscall.EINTR) {
+			continue
 		}
-		if b[0] == '\n' || b[0] == '\r' {
+		if err == nil && n == 0 {
+			if empty++; empty < maxEmptyReads {
+				continue
+			}
+			err = io.ErrNoProgress
+		}
+		if err != nil {
+			clearbuf(pbuf[:i])
+			return nil, err
+		}
+		empty = 0
+		if isLineEnd(b[0]) {
 			return pbuf[:i], nil
 		}
 		pbuf[i] = b[0]
 		b[0] = 0
+		i++
 	}
-	clearbuf(pbuf[:i+1])
+	clearbuf(pbuf)
 	return nil, errors.New("ran out of bufferspace")
 }
 
+// isLineEnd returns true if b terminates a line.
+// Depending on ICRNL/INLCR Enter arrives as either '\r' or '\n' so both are accepted.
+func isLineEnd(b byte) bool {
+	return b == '\n' || b == '\r'
+}
+
 // clearbuf clears out the buffer incase we couldn't read the full password.
 func clearbuf(b []byte) {
 	for i := range b {
@@ -181,6 +749,170 @@ func GetChar(f *os.File) (b byte, err error) {
 	return bs[0], err
 }
 
+// CharReader is GetChar for loops reading a byte at a time, reading ahead into a
+// buffer kept between calls so the reads don't allocate.
+//
+// The CharReader has to be the only reader of the file once created, anything it read
+// ahead is lost to other readers. Not safe for use from several goroutines.
+type CharReader struct {
+	f    *os.File
+	buf  [4096]byte
+	r, w int // r, w read and write positions in buf
+}
+
+// NewCharReader returns a CharReader reading from f.
+func NewCharReader(f *os.File) *CharReader {
+	return &CharReader{f: f}
+}
+
+// Get returns the next byte, reading more from the file when the buffer is used up.
+func (cr *CharReader) Get() (byte, error) {
+	for cr.r == cr.w {
+		n, err := cr.f.Read(cr.buf[:])
+		if err != nil {
+			return 0, err
+		}
+		cr.r, cr.w = 0, n
+	}
+	b := cr.buf[cr.r]
+	cr.r++
+	return b, nil
+}
+
+// Buffered returns the number of bytes read ahead and waiting to be returned by Get.
+func (cr *CharReader) Buffered() int {
+	return cr.w - cr.r
+}
+
+// ReadByteTimeout reads a single byte from f waiting at most d for it to arrive.
+// ErrTimeout is returned if nothing was read within d.
+// The terminal should be in non-canonical mode, eg. Raw(), since in canonical mode
+// nothing is readable until a full line has been entered.
+func ReadByteTimeout(f *os.File, d time.Duration) (byte, error) {
+	ok, err := pollIn(f, d)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrTimeout
+	}
+	return GetChar(f)
+}
+
+// HasInput reports whether there's input waiting to be read from f, eg. typeahead
+// before showing a prompt, without blocking. In canonical mode input only counts
+// once a whole line has been typed.
+func HasInput(f *os.File) (bool, error) {
+	return pollIn(f, 0)
+}
+
+// FPrint writes s to the terminal f, turning "\n" into "\r\n" when f does not do so
+// itself, ie. OPOST or ONLCR is off as in raw mode, where plain "\n" ended lines would
+// staircase. "\r\n" is left as is. Library code can print messages this way whatever
+// mode the terminal is in. The mode is asked for on every call, s goes out as is, with
+// no copying, when f translates newlines, has none to translate or isn't a terminal.
+// Returns the bytes written to f, any "\r" added included.
+func FPrint(f *os.File, s string) (int, error) {
+	var t Termios
+	onlcr := uint32(syscall.OPOST | syscall.ONLCR)
+	if !strings.Contains(s, "\n") || AttrInto(f, &t) != nil || t.Oflag&onlcr == onlcr {
+		return f.WriteString(s)
+	}
+	b := make([]byte, 0, len(s)+strings.Count(s, "\n"))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' && (i == 0 || s[i-1] != '\r') {
+			b = append(b, '\r')
+		}
+		b = append(b, s[i])
+	}
+	return f.Write(b)
+}
+
+// pollIn waits up to d for f to become readable.
+// Returns false with no error if d passed without any input.
+func pollIn(f *os.File, d time.Duration) (bool, error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	ms := int((d + time.Millisecond - 1) / time.Millisecond)
+	if d < 0 {
+		ms = 0
+	}
+	var n int
+	var perr error
+	if err := rc.Control(func(fd uintptr) {
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for {
+			if n, perr = unix.Poll(fds, ms); perr != unix.EINTR {
+				return
+			}
+		}
+	}); err != nil {
+		return false, err
+	}
+	if perr != nil {
+		return false, perr
+	}
+	return n > 0, nil
+}
+
+// SetBlocking clears (blocking true) or sets O_NONBLOCK on f with fcntl, eg. handing
+// a fd left non-blocking by a cancelable read to a child process or C code expecting
+// blocking reads. Returns the errno when fcntl fails.
+//
+// This changes the fd under the Go runtime, which decided when f was opened whether
+// to use its poller and doesn't find out, so either way can break f for Go.
+// Clearing O_NONBLOCK on a pollable f, like the Master from OpenPTY, leaves reads
+// blocked in the syscall where deadlines and Close can't reach them, so ProxyContext
+// cancelling and Shutdown stop working; set it back before using f from Go again.
+// Setting O_NONBLOCK on an f outside the poller, like os.Stdin, makes Read and Write
+// return EAGAIN instead of waiting.
+func SetBlocking(f *os.File, blocking bool) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = unix.SetNonblock(int(fd), !blocking)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// Flow control actions for Flow.
+const (
+	FlowSuspendOutput = unix.TCOOFF // FlowSuspendOutput Stop sending output to the terminal
+	FlowResumeOutput  = unix.TCOON  // FlowResumeOutput  Restart output stopped with FlowSuspendOutput or XOFF
+	FlowSuspendInput  = unix.TCIOFF // FlowSuspendInput  Send a STOP char asking the device to stop sending data
+	FlowResumeInput   = unix.TCION  // FlowResumeInput   Send a START char asking the device to resume sending data
+)
+
+// Flow suspends or resumes I/O on file, see the Flow* constants for the actions.
+// Returns the errno if the action fails eg. EINVAL for an unknown action.
+func (t *Termios) Flow(file *os.File, action int) error {
+	return tcflow(file, action)
+}
+
+// RestartOutput resumes output on f that was suspended, eg. by a received XOFF (^S).
+//
+// Linux does not expose whether output currently is suspended so there is no way
+// to query it, restarting output that is not suspended is harmless though.
+func RestartOutput(f *os.File) error {
+	return tcflow(f, FlowResumeOutput)
+}
+
+// tcflow is the equivalent of the C tcflow, suspending or restarting I/O on file.
+func tcflow(file *os.File, action int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(file.Fd()), uintptr(unix.TCXONC), uintptr(action))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
 // Winsz Fetches the current terminal windowsize.
 // example handling changing window sizes with PTYs:
 //
@@ -212,11 +944,73 @@ func (t *Termios) Setwinsz(file *os.File) error {
 	return nil
 }
 
+// CellSize returns the size in pixels of a character cell, from the pixel and
+// character sizes of w. Many terminals leave the pixel sizes 0, ok is then false.
+func (w Winsize) CellSize() (wpx, hpx int, ok bool) {
+	if w.WsXpixel == 0 || w.WsYpixel == 0 || w.WsCol == 0 || w.WsRow == 0 {
+		return 0, 0, false
+	}
+	wpx, hpx = int(w.WsXpixel)/int(w.WsCol), int(w.WsYpixel)/int(w.WsRow)
+	if wpx == 0 || hpx == 0 {
+		return 0, 0, false
+	}
+	return wpx, hpx, true
+}
+
+// CellsForPixels returns the number of columns and rows needed to fit an area of
+// px by py pixels, eg. an image for sixel or the kitty graphics protocol.
+// Partly covered cells count. ok is false when w has no pixel sizes, see CellSize.
+func CellsForPixels(w Winsize, px, py int) (cols, rows int, ok bool) {
+	wpx, hpx, ok := w.CellSize()
+	if !ok {
+		return 0, 0, false
+	}
+	return (px + wpx - 1) / wpx, (py + hpx - 1) / hpx, true
+}
+
+// CopyAttr sets the attributes and window size of dst to those of src, eg. making
+// a new PTY Slave match the terminal the program runs in, like script and ssh do.
+func CopyAttr(dst, src *os.File) error {
+	if !Isatty(src) {
+		return errors.New(src.Name() + " is not a tty")
+	}
+	t, err := Attr(src)
+	if err != nil {
+		return err
+	}
+	if err := t.Winsz(src); err != nil {
+		return err
+	}
+	if err := t.Set(dst); err != nil {
+		return err
+	}
+	return t.Setwinsz(dst)
+}
+
 // Close closes the PTYs that OpenPTY created.
+// Closing an already closed PTY does nothing and returns nil.
 func (p *PTY) Close() error {
-	slaveErr := errors.New("Slave FD nil")
-	if p.Slave != nil {
-		slaveErr = p.Slave.Close()
+	p.mu.Lock()
+	closed := p.closed
+	p.closed = true
+	log, slave := p.log, p.Slave
+	p.mu.Unlock()
+	if closed {
+		return nil
+	}
+	err := p.close(slave)
+	if log != nil {
+		log.Closed(err)
+	}
+	return err
+}
+
+// close closes slave, the Slave as read under mu, and the Master.
+func (p *PTY) close(slave *os.File) error {
+	// The Slave is nil when it's only open in the child, see Shell.
+	var slaveErr error
+	if slave != nil {
+		slaveErr = slave.Close()
 	}
 	masterErr := errors.New("Master FD nil")
 	if p.Master != nil {
@@ -239,7 +1033,20 @@ func (p *PTY) Close() error {
 func (p *PTY) ReadByte() (byte, error) {
 	bs := make([]byte, 1, 1)
 	_, err := p.Master.Read(bs)
-	return bs[0], err
+	return bs[0], p.closedErr(err)
+}
+
+// closedErr wraps err with ErrPTYClosed when p has been closed.
+func (p *PTY) closedErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrPTYClosed, err)
 }
 
 // GetChar fine old getchar() for a PTY.
@@ -2,19 +2,25 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-
 package term
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
+	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"testing"
+	"testing/iotest"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 var pty *PTY
@@ -113,6 +119,38 @@ func TestClose(t *testing.T) {
 	}
 }
 
+// TestClosedPTY tests using a PTY after Close.
+func TestClosedPTY(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	if err := tty.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := tty.Close(); err != nil {
+		t.Errorf("Close of closed PTY got: %v want: <nil>", err)
+	}
+	if _, err := tty.PTSName(); !errors.Is(err, ErrPTYClosed) || err == ErrPTYClosed {
+		t.Errorf("PTSName after Close got: %v want: %v wrapping the close error", err, ErrPTYClosed)
+	}
+	if _, err := tty.PTSNumber(); !errors.Is(err, ErrPTYClosed) {
+		t.Errorf("PTSNumber after Close got: %v want: %v", err, ErrPTYClosed)
+	}
+	if _, err := tty.ReadByte(); !errors.Is(err, ErrPTYClosed) {
+		t.Errorf("ReadByte after Close got: %v want: %v", err, ErrPTYClosed)
+	}
+	if _, err := tty.WinsizeSlave(); !errors.Is(err, ErrPTYClosed) {
+		t.Errorf("WinsizeSlave after Close got: %v want: %v", err, ErrPTYClosed)
+	}
+	if err := tty.SetSlaveRaw(); !errors.Is(err, ErrPTYClosed) {
+		t.Errorf("SetSlaveRaw after Close got: %v want: %v", err, ErrPTYClosed)
+	}
+	if err := tty.Signal(syscall.SIGINT); !errors.Is(err, ErrPTYClosed) {
+		t.Errorf("Signal after Close got: %v want: %v", err, ErrPTYClosed)
+	}
+}
+
 // TestIsatty checks Isatty on a standard file and a tty.
 func TestIsatty(t *testing.T) {
 	f, err := donormfile("TestIsatty")
@@ -175,6 +213,15 @@ func TestGetPass(t *testing.T) {
 	if string(pass) != tststring[:len(tststring)-1] {
 		t.Errorf("GetPass got: %q want: %q", pass, tststring)
 	}
+	// The prompt might not be read off the Master yet.
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := readbuffer.Len()
+		mu.Unlock()
+		if n >= len("TestGetPass:") {
+			break
+		}
+	}
 	mu.Lock()
 	if readbuffer.String() != "TestGetPass:" {
 		t.Errorf("GetPass got: %q want: %q", readbuffer.String(), "TestGetPass:")
@@ -196,6 +243,53 @@ func TestGetPass(t *testing.T) {
 	}
 }
 
+// eintrReader returns EINTR on every other Read.
+type eintrReader struct {
+	r    io.Reader
+	intr bool
+}
+
+func (er *eintrReader) Read(b []byte) (int, error) {
+	if er.intr = !er.intr; er.intr {
+		return 0, syscall.EINTR
+	}
+	return er.r.Read(b)
+}
+
+// emptyReader returns nothing, and no error, on every Read.
+type emptyReader struct{}
+
+func (emptyReader) Read(b []byte) (int, error) {
+	return 0, nil
+}
+
+// TestReadPass tests the GetPass reading retrying on EINTR and returning errors.
+func TestReadPass(t *testing.T) {
+	buf := make([]byte, 64)
+	pass, err := readPass(&eintrReader{r: strings.NewReader("secret\nrest")}, buf)
+	if err != nil || string(pass) != "secret" {
+		t.Errorf("readPass with EINTR got: %q, %v want: %q, <nil>", pass, err, "secret")
+	}
+	pass, err = readPass(&eintrReader{r: strings.NewReader("cut")}, buf)
+	if err != io.EOF || pass != nil {
+		t.Errorf("readPass at EOF got: %q, %v want: <nil>, %v", pass, err, io.EOF)
+	}
+	if string(buf[:3]) != "\x00\x00\x00" {
+		t.Errorf("readPass should clear buffer on errors got: %q", buf[:3])
+	}
+	_, err = readPass(iotest.ErrReader(syscall.EIO), buf)
+	if !errors.Is(err, syscall.EIO) {
+		t.Errorf("readPass got: %v want: %v", err, syscall.EIO)
+	}
+	_, err = readPass(io.MultiReader(strings.NewReader("ab"), emptyReader{}), buf)
+	if err != io.ErrNoProgress {
+		t.Errorf("readPass of empty reads got: %v want: %v", err, io.ErrNoProgress)
+	}
+	if string(buf[:2]) != "\x00\x00" {
+		t.Errorf("readPass should clear buffer on no progress got: %q", buf[:2])
+	}
+}
+
 // TestGetChar tests out both the GetChar functions.
 func TestGetChar(t *testing.T) {
 	pty, err := OpenPTY()
@@ -262,6 +356,54 @@ func TestPTSName(t *testing.T) {
 	}
 }
 
+// TestListPTS tests the slave of a new PTY shows up in the list.
+func TestListPTS(t *testing.T) {
+	name, err := pty.PTSName()
+	if err != nil {
+		t.Fatalf("PTSName failed: %v", err)
+	}
+	pts, err := ListPTS()
+	if err != nil {
+		t.Fatalf("ListPTS failed: %v", err)
+	}
+	found := false
+	for _, p := range pts {
+		if p == name {
+			found = true
+		}
+		if strings.HasSuffix(p, "ptmx") {
+			t.Errorf("ListPTS got: %q want: no ptmx", p)
+		}
+	}
+	if !found {
+		t.Errorf("ListPTS got: %q want: %q included", pts, name)
+	}
+}
+
+// TestOpenPTYName tests the slave named can be opened and talks to the master.
+func TestOpenPTYName(t *testing.T) {
+	master, name, err := OpenPTYName()
+	if err != nil {
+		t.Fatalf("OpenPTYName failed: %v", err)
+	}
+	defer master.Close()
+	if !strings.HasPrefix(name, "/dev/pts/") {
+		t.Errorf("OpenPTYName name got: %q want: /dev/pts/N", name)
+	}
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatalf("Opening slave %q failed: %v", name, err)
+	}
+	defer slave.Close()
+	if _, err := slave.Write([]byte("hi")); err != nil {
+		t.Fatalf("Writing slave failed: %v", err)
+	}
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(master, b); err != nil || string(b) != "hi" {
+		t.Errorf("Reading master got: %q, %v want: %q, <nil>", b, err, "hi")
+	}
+}
+
 // TestWinsz Tests if we can fetch the Terminal size.
 // Also sanity checks with a normal file.
 func TestWinsz(t *testing.T) {
@@ -394,3 +536,1107 @@ func TestTattr(t *testing.T) {
 		t.Error("Tattr, should not be able to get attributes from regular file: ", nf.Name())
 	}
 }
+
+// TestPredicates tests the Termios state predicates.
+func TestPredicates(t *testing.T) {
+	var tr Termios
+	tr.Lflag = syscall.ECHO | syscall.ICANON | syscall.ISIG
+	tr.Oflag = syscall.OPOST
+	if tr.IsRaw() {
+		t.Error("IsRaw() for cooked terminal got: true want: false")
+	}
+	if !tr.IsCanonical() || !tr.EchoEnabled() || !tr.SignalsEnabled() {
+		t.Errorf("IsCanonical, EchoEnabled, SignalsEnabled got: %t %t %t want: true true true", tr.IsCanonical(), tr.EchoEnabled(), tr.SignalsEnabled())
+	}
+	tr.Raw()
+	if !tr.IsRaw() {
+		t.Error("IsRaw() after Raw() got: false want: true")
+	}
+	if tr.IsCanonical() || tr.EchoEnabled() || tr.SignalsEnabled() {
+		t.Errorf("IsCanonical, EchoEnabled, SignalsEnabled got: %t %t %t want: false false false", tr.IsCanonical(), tr.EchoEnabled(), tr.SignalsEnabled())
+	}
+	tr.Cook()
+	if tr.IsRaw() {
+		t.Error("IsRaw() after Cook() got: true want: false")
+	}
+}
+
+// TestWouldChange tests WouldChange and NeedsRaw.
+func TestWouldChange(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	if !tr.NeedsRaw() {
+		t.Error("NeedsRaw() for cooked terminal got: false want: true")
+	}
+	if !tr.IsCanonical() {
+		t.Error("NeedsRaw() should not modify the receiver")
+	}
+	tr.Raw()
+	if tr.NeedsRaw() {
+		t.Error("NeedsRaw() for raw terminal got: true want: false")
+	}
+	if tr.WouldChange(func(nt *Termios) { nt.Cc[syscall.VMIN] = 1 }) {
+		t.Error("WouldChange(VMIN=1) on raw terminal got: true want: false")
+	}
+	if !tr.WouldChange((*Termios).Cook) {
+		t.Error("WouldChange(Cook) on raw terminal got: false want: true")
+	}
+}
+
+// TestOpenPTYAt tests opening PTYs from a specified ptmx device.
+func TestOpenPTYAt(t *testing.T) {
+	tty, err := OpenPTYAt("")
+	if err != nil {
+		t.Fatalf("OpenPTYAt(\"\") failed: %v", err)
+	}
+	defer tty.Close()
+	name, err := tty.PTSName()
+	if err != nil {
+		t.Fatalf("PTSName failed: %v", err)
+	}
+	if !strings.HasPrefix(name, "/dev/pts/") {
+		t.Errorf("PTSName got: %q want: /dev/pts/N", name)
+	}
+	if _, err := OpenPTYAt("/nonexistent/ptmx"); err == nil {
+		t.Error("OpenPTYAt(\"/nonexistent/ptmx\") got: <nil> want: error")
+	}
+	for _, tst := range []struct {
+		ptmx string
+		want string
+	}{
+		{"/dev/ptmx", "/dev/pts"},
+		{"/dev/pts/ptmx", "/dev/pts"},
+		{"/container/dev/ptmx", "/container/dev/pts"},
+		{"/container/dev/pts/ptmx", "/container/dev/pts"},
+	} {
+		if got := ptsDirFor(tst.ptmx); got != tst.want {
+			t.Errorf("ptsDirFor(%q) got: %q want: %q", tst.ptmx, got, tst.want)
+		}
+	}
+}
+
+// TestReadByteTimeout tests reading a byte with a timeout from a raw PTY.
+func TestReadByteTimeout(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Raw()
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if _, err := ReadByteTimeout(tty.Slave, 50*time.Millisecond); err != ErrTimeout {
+		t.Errorf("ReadByteTimeout with no input got: %v want: %v", err, ErrTimeout)
+	}
+	if _, err := tty.Master.Write([]byte("x")); err != nil {
+		t.Fatalf("Write to master failed: %v", err)
+	}
+	b, err := ReadByteTimeout(tty.Slave, time.Second)
+	if err != nil {
+		t.Fatalf("ReadByteTimeout failed: %v", err)
+	}
+	if b != 'x' {
+		t.Errorf("ReadByteTimeout got: %q want: %q", b, 'x')
+	}
+}
+
+// TestResetTerminal tests resetting a PTY in raw mode.
+func TestResetTerminal(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Raw()
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := ResetTerminal(tty.Slave); err != nil {
+		t.Fatalf("ResetTerminal failed: %v", err)
+	}
+	if tr, err = Attr(tty.Slave); err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if !tr.IsCanonical() || !tr.EchoEnabled() || !tr.SignalsEnabled() || tr.Oflag&syscall.OPOST == 0 {
+		t.Errorf("ResetTerminal did not set sane attributes got: %+v", tr)
+	}
+	b := make([]byte, 16)
+	n, err := tty.Master.Read(b)
+	if err != nil {
+		t.Fatalf("Read from master failed: %v", err)
+	}
+	if string(b[:n]) != "\033c" {
+		t.Errorf("ResetTerminal wrote: %q want: %q", b[:n], "\033c")
+	}
+	nf, err := donormfile("TestResetTerminal")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if err := ResetTerminal(nf); err == nil {
+		t.Error("ResetTerminal on a regular file got: <nil> want: error")
+	}
+}
+
+// TestSetUTF8 tests toggling the IUTF8 flag on a PTY.
+func TestSetUTF8(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	for _, on := range []bool{true, false, true} {
+		tr, err := Attr(tty.Slave)
+		if err != nil {
+			t.Fatalf("Attr failed: %v", err)
+		}
+		tr.SetUTF8(on)
+		if err := tr.Set(tty.Slave); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if tr, err = Attr(tty.Slave); err != nil {
+			t.Fatalf("Attr failed: %v", err)
+		}
+		if got := tr.Iflag&syscall.IUTF8 != 0; got != on {
+			t.Errorf("SetUTF8(%t) got: %t want: %t", on, got, on)
+		}
+	}
+}
+
+// TestRestartOutput tests restarting output on a PTY and a normal file.
+func TestRestartOutput(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	if err := RestartOutput(tty.Slave); err != nil {
+		t.Errorf("RestartOutput(tty.Slave) failed: %v", err)
+	}
+	nf, err := donormfile("TestRestartOutput")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if err := RestartOutput(nf); err == nil {
+		t.Error("RestartOutput on a regular file got: <nil> want: error")
+	}
+}
+
+// TestFlow tests suspending and resuming I/O on a PTY.
+func TestFlow(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	var tr Termios
+	for _, action := range []int{FlowSuspendOutput, FlowResumeOutput, FlowSuspendInput, FlowResumeInput} {
+		if err := tr.Flow(tty.Slave, action); err != nil {
+			t.Errorf("Flow(tty.Slave, %d) failed: %v", action, err)
+		}
+	}
+	if err := tr.Flow(tty.Slave, 42); err != syscall.EINVAL {
+		t.Errorf("Flow(tty.Slave, 42) got: %v want: %v", err, syscall.EINVAL)
+	}
+}
+
+// TestIsLineEnd tests the line terminator check.
+func TestIsLineEnd(t *testing.T) {
+	for b := 0; b < 256; b++ {
+		want := b == '\n' || b == '\r'
+		if got := isLineEnd(byte(b)); got != want {
+			t.Errorf("isLineEnd(%q) got: %t want: %t", b, got, want)
+		}
+	}
+}
+
+// TestErase tests setting and normalizing the erase char.
+func TestErase(t *testing.T) {
+	var tr Termios
+	for _, tst := range []struct {
+		set  byte
+		want byte
+	}{
+		{EraseBS, EraseBS},
+		{EraseDEL, EraseDEL},
+		{0, EraseDEL},
+		{'x', EraseDEL},
+	} {
+		tr.SetErase(tst.set)
+		if tr.Cc[syscall.VERASE] != tst.set {
+			t.Errorf("SetErase(%q) got: %q want: %q", tst.set, tr.Cc[syscall.VERASE], tst.set)
+		}
+		tr.NormalizeErase()
+		if tr.Cc[syscall.VERASE] != tst.want {
+			t.Errorf("NormalizeErase() of %q got: %q want: %q", tst.set, tr.Cc[syscall.VERASE], tst.want)
+		}
+	}
+}
+
+// TestReconcileErase tests the erase and line ending fixes.
+func TestReconcileErase(t *testing.T) {
+	canon := uint32(syscall.ICANON | syscall.ECHO)
+	tests := []struct {
+		name      string
+		iflag     uint32
+		lflag     uint32
+		erase     byte
+		eol       byte
+		wantIflag uint32
+		wantLflag uint32
+		wantErase byte
+	}{
+		{"disabled erase", 0, canon, 0, 0, syscall.ICRNL, canon | syscall.ECHOE, EraseDEL},
+		{"bs kept", syscall.ICRNL, canon, EraseBS, 0, syscall.ICRNL, canon | syscall.ECHOE, EraseBS},
+		{"eol cr", 0, canon, EraseDEL, '\r', 0, canon | syscall.ECHOE, EraseDEL},
+		{"no echo", 0, syscall.ICANON, EraseDEL, 0, syscall.ICRNL, syscall.ICANON, EraseDEL},
+		{"raw", 0, 0, 'x', 0, 0, 0, EraseDEL},
+	}
+	for _, tst := range tests {
+		tr := Termios{Iflag: tst.iflag, Lflag: tst.lflag}
+		tr.Cc[syscall.VERASE], tr.Cc[syscall.VEOL] = tst.erase, tst.eol
+		tr.ReconcileErase()
+		if tr.Iflag != tst.wantIflag || tr.Lflag != tst.wantLflag || tr.Cc[syscall.VERASE] != tst.wantErase {
+			t.Errorf("ReconcileErase %s got: %#x, %#x, %q want: %#x, %#x, %q", tst.name,
+				tr.Iflag, tr.Lflag, tr.Cc[syscall.VERASE], tst.wantIflag, tst.wantLflag, tst.wantErase)
+		}
+	}
+}
+
+// TestApplyDiff tests that ApplyDiff only changes what differs.
+func TestApplyDiff(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	base, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	base.Lflag |= syscall.ECHO
+	base.Iflag |= syscall.IXON
+	if err := base.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	mine := base
+	mine.Lflag &^= syscall.ECHO
+	mine.Cc[syscall.VINTR] = 'x'
+	// Someone else changes the terminal.
+	other := base
+	other.Iflag &^= syscall.IXON
+	other.Cc[syscall.VQUIT] = 'y'
+	if err := other.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := mine.ApplyDiff(tty.Slave, base); err != nil {
+		t.Fatalf("ApplyDiff failed: %v", err)
+	}
+	got, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if got.Lflag&syscall.ECHO != 0 || got.Cc[syscall.VINTR] != 'x' {
+		t.Errorf("ApplyDiff did not apply the changes, Lflag: %x VINTR: %q", got.Lflag, got.Cc[syscall.VINTR])
+	}
+	if got.Iflag&syscall.IXON != 0 || got.Cc[syscall.VQUIT] != 'y' {
+		t.Errorf("ApplyDiff overwrote other changes, Iflag: %x VQUIT: %q", got.Iflag, got.Cc[syscall.VQUIT])
+	}
+	nf, err := donormfile("TestApplyDiff")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if err := mine.ApplyDiff(nf, base); err == nil {
+		t.Error("ApplyDiff on a regular file got: <nil> want: error")
+	}
+}
+
+// TestWinsizeSlave tests reading the Slave window size.
+func TestWinsizeSlave(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	var tr Termios
+	tr.Wz = Winsize{WsRow: 24, WsCol: 80, WsXpixel: 640, WsYpixel: 480}
+	if err := tr.Setwinsz(tty.Slave); err != nil {
+		t.Fatalf("Setwinsz failed: %v", err)
+	}
+	wz, err := tty.WinsizeSlave()
+	if err != nil {
+		t.Fatalf("WinsizeSlave failed: %v", err)
+	}
+	if wz != tr.Wz {
+		t.Errorf("WinsizeSlave got: %+v want: %+v", wz, tr.Wz)
+	}
+	tty.Slave.Close()
+	if _, err := tty.WinsizeSlave(); err == nil {
+		t.Error("WinsizeSlave on closed Slave got: <nil> want: error")
+	}
+}
+
+// TestStdStreams tests the cached standard stream checks.
+func TestStdStreams(t *testing.T) {
+	for _, tst := range []struct {
+		name string
+		f    *os.File
+		tty  func() bool
+	}{
+		{"Stdin", os.Stdin, Stdin},
+		{"Stdout", os.Stdout, Stdout},
+		{"Stderr", os.Stderr, Stderr},
+	} {
+		want := Isatty(tst.f)
+		for i := 0; i < 2; i++ {
+			if got := tst.tty(); got != want {
+				t.Errorf("%s() got: %t want: %t", tst.name, got, want)
+			}
+		}
+	}
+}
+
+// TestRawWithSignals tests raw mode keeping the signal keys.
+func TestRawWithSignals(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	tr.RawWithSignals()
+	if !tr.SignalsEnabled() {
+		t.Error("RawWithSignals() SignalsEnabled got: false want: true")
+	}
+	tr.Lflag &^= syscall.ISIG
+	if err := testraw(tr, "TestRawWithSignals"); err != nil {
+		t.Errorf("RawWithSignals failed: %v", err)
+	}
+}
+
+// TestNoEchoNoSignal tests the secret entry mode.
+func TestNoEchoNoSignal(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	tr.Lflag |= syscall.ECHO | syscall.ECHONL
+	want := tr
+	tr.NoEchoNoSignal()
+	if tr.EchoEnabled() || tr.SignalsEnabled() || tr.Lflag&syscall.ECHONL != 0 {
+		t.Errorf("NoEchoNoSignal() Lflag got: %#x want: ECHO, ECHONL and ISIG off", tr.Lflag)
+	}
+	if !tr.IsCanonical() {
+		t.Error("NoEchoNoSignal() IsCanonical got: false want: true")
+	}
+	if tr.Iflag != want.Iflag || tr.Oflag != want.Oflag || tr.Cflag != want.Cflag {
+		t.Errorf("NoEchoNoSignal() changed other flags got: %v want: %v", tr, want)
+	}
+	tr.EchoAndSignals()
+	want.Lflag &^= syscall.ECHONL
+	if tr != want {
+		t.Errorf("EchoAndSignals() got: %v want: %v", tr, want)
+	}
+}
+
+// TestSetNoFlush tests toggling NOFLSH.
+func TestSetNoFlush(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	want := tr
+	tr.SetNoFlush(true)
+	if tr.Lflag != want.Lflag|syscall.NOFLSH {
+		t.Errorf("SetNoFlush(true) Lflag got: %#x want: %#x", tr.Lflag, want.Lflag|syscall.NOFLSH)
+	}
+	tr.SetNoFlush(false)
+	if tr != want {
+		t.Errorf("SetNoFlush(false) got: %v want: %v", tr, want)
+	}
+}
+
+// TestSetStopOnBackgroundWrite tests toggling TOSTOP on the Slave.
+func TestSetStopOnBackgroundWrite(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	for _, on := range []bool{true, false} {
+		tr, err := Attr(tty.Slave)
+		if err != nil {
+			t.Fatalf("Attr failed: %v", err)
+		}
+		tr.SetStopOnBackgroundWrite(on)
+		if err := tr.Set(tty.Slave); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		if tr, err = Attr(tty.Slave); err != nil {
+			t.Fatalf("Attr failed: %v", err)
+		}
+		if got := tr.Lflag&syscall.TOSTOP != 0; got != on {
+			t.Errorf("SetStopOnBackgroundWrite(%t) got: %t want: %t", on, got, on)
+		}
+	}
+}
+
+// TestSetLineEditing tests disabling and restoring the line editing characters.
+func TestSetLineEditing(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	tr.Cc[syscall.VERASE] = EraseBS
+	tr.Cc[syscall.VKILL] = 0x18
+	tr.Cc[syscall.VINTR] = 3
+	mine := tr.LineEditChars()
+	prev := tr.SetLineEditing(false)
+	if prev != mine {
+		t.Errorf("SetLineEditing(false) returned: %+v want: %+v", prev, mine)
+	}
+	if got := tr.LineEditChars(); got != (LineEditChars{}) {
+		t.Errorf("SetLineEditing(false) got: %+v want: all 0", got)
+	}
+	if !tr.IsCanonical() || tr.Cc[syscall.VINTR] != 3 {
+		t.Errorf("SetLineEditing(false) changed more got: %v", tr)
+	}
+	tr.SetLineEditChars(prev)
+	if got := tr.LineEditChars(); got != mine {
+		t.Errorf("SetLineEditChars got: %+v want: %+v", got, mine)
+	}
+	// On without the saved ones fills in the conventional characters.
+	tr.SetLineEditing(false)
+	tr.Cc[syscall.VKILL] = 0x15
+	if prev := tr.SetLineEditing(true); prev != (LineEditChars{Kill: 0x15}) {
+		t.Errorf("SetLineEditing(true) returned: %+v want: %+v", prev, LineEditChars{Kill: 0x15})
+	}
+	if want := (LineEditChars{EraseDEL, 0x15, 0x17}); tr.LineEditChars() != want {
+		t.Errorf("SetLineEditing(true) got: %+v want: %+v", tr.LineEditChars(), want)
+	}
+}
+
+// TestEchoEraseFlags tests the erase and kill echo toggles.
+func TestEchoEraseFlags(t *testing.T) {
+	tests := []struct {
+		set func(*Termios, bool)
+		bit uint32
+	}{
+		{(*Termios).SetEchoErase, syscall.ECHOE},
+		{(*Termios).SetEchoKill, syscall.ECHOK},
+		{(*Termios).SetEchoKillErase, syscall.ECHOKE},
+	}
+	for _, tst := range tests {
+		var tr Termios
+		tr.Cook()
+		want := tr
+		tst.set(&tr, true)
+		if tr.Lflag != want.Lflag|tst.bit {
+			t.Errorf("set %#x Lflag got: %#x want: %#x", tst.bit, tr.Lflag, want.Lflag|tst.bit)
+		}
+		tst.set(&tr, false)
+		if tr != want {
+			t.Errorf("clear %#x got: %v want: %v", tst.bit, tr, want)
+		}
+	}
+}
+
+// TestFlowKeys tests toggling the XON/XOFF flags.
+func TestFlowKeys(t *testing.T) {
+	var tr Termios
+	tr.Cook()
+	tr.Iflag |= syscall.IXOFF
+	lflag := tr.Lflag
+	tr.DisableFlowKeys()
+	if tr.Iflag&(syscall.IXON|syscall.IXOFF) != 0 {
+		t.Errorf("DisableFlowKeys() Iflag got: %x want IXON and IXOFF cleared", tr.Iflag)
+	}
+	if tr.Lflag != lflag {
+		t.Errorf("DisableFlowKeys() changed Lflag got: %x want: %x", tr.Lflag, lflag)
+	}
+	tr.EnableFlowKeys()
+	if tr.Iflag&syscall.IXON == 0 {
+		t.Errorf("EnableFlowKeys() Iflag got: %x want IXON set", tr.Iflag)
+	}
+}
+
+// TestSignal tests signalling the foreground process group of the Slave.
+func TestSignal(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	if err := tty.Signal(syscall.SIGINT); err == nil {
+		t.Error("Signal without a session got: <nil> want: error")
+	}
+	cmd := exec.Command("sleep", "10")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty.Slave, tty.Slave, tty.Slave
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("starting sleep failed: %v", err)
+	}
+	if err := tty.Signal(syscall.SIGTERM); err != nil {
+		cmd.Process.Kill()
+		t.Fatalf("Signal failed: %v", err)
+	}
+	err = cmd.Wait()
+	ws, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() || ws.Signal() != syscall.SIGTERM {
+		t.Errorf("Signal(SIGTERM) child exit got: %v want: signal: terminated", err)
+	}
+}
+
+// TestShell tests starting a shell on a new PTY, also without a usable $SHELL.
+func TestShell(t *testing.T) {
+	for _, shell := range []string{"/bin/sh", "", "/no/such/shell"} {
+		t.Setenv("SHELL", shell)
+		tty, proc, err := Shell([]string{"PS1=$ "}, Winsize{WsRow: 30, WsCol: 100})
+		if err != nil {
+			t.Fatalf("Shell with SHELL=%q failed: %v", shell, err)
+		}
+		if tty.Slave != nil {
+			t.Errorf("Shell left the Slave open in the parent")
+		}
+		tty.Master.Write([]byte("echo size $(stty size) $((40+2))\nexit 3\n"))
+		var out strings.Builder
+		if err := tty.ForEachLine(func(line string) error {
+			out.WriteString(line + "\n")
+			return nil
+		}); err != nil {
+			t.Errorf("ForEachLine failed: %v", err)
+		}
+		if !strings.Contains(out.String(), "size 30 100 42") {
+			t.Errorf("Shell with SHELL=%q output got: %q want: size 30 100 42", shell, out.String())
+		}
+		if ps, err := tty.Wait(proc); err != nil || ps.ExitCode() != 3 {
+			t.Errorf("Shell with SHELL=%q exit got: %v, %v want: exit status 3", shell, ps, err)
+		}
+		if err := tty.Close(); err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	}
+}
+
+// TestCCName tests the control character names.
+func TestCCName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{syscall.VINTR, "intr"},
+		{syscall.VERASE, "erase"},
+		{syscall.VMIN, "min"},
+		{syscall.VLNEXT, "lnext"},
+		{syscall.VEOL2, "eol2"},
+		{30, "cc30"},
+	}
+	for _, tst := range tests {
+		if got := CCName(tst.index); got != tst.want {
+			t.Errorf("CCName(%d) got: %q want: %q", tst.index, got, tst.want)
+		}
+	}
+}
+
+// TestFormatCC tests showing control characters.
+func TestFormatCC(t *testing.T) {
+	tests := []struct {
+		in   byte
+		want string
+	}{
+		{0, "<undef>"},
+		{0x03, "^C"},
+		{0x1c, "^\\"},
+		{0x1f, "^_"},
+		{0x7f, "^?"},
+		{'a', "a"},
+		{0x83, "M-^C"},
+		{0xff, "M-^?"},
+		{0xe1, "M-a"},
+	}
+	for _, tst := range tests {
+		if got := FormatCC(tst.in); got != tst.want {
+			t.Errorf("FormatCC(%#x) got: %q want: %q", tst.in, got, tst.want)
+		}
+	}
+}
+
+// TestCaret tests caret notation and that stty reads it back.
+func TestCaret(t *testing.T) {
+	tests := []struct {
+		in   byte
+		want string
+	}{
+		{0, "^@"},
+		{EraseBS, "^H"},
+		{0x1b, "^["},
+		{EraseDEL, "^?"},
+		{' ', " "},
+		{'~', "~"},
+		{0x80, "M-^@"},
+		{0x80 | EraseDEL, "M-^?"},
+		{0xe1, "M-a"},
+	}
+	for _, tst := range tests {
+		if got := Caret(tst.in); got != tst.want {
+			t.Errorf("Caret(%#x) got: %q want: %q", tst.in, got, tst.want)
+		}
+	}
+	for i := 0; i < 256; i++ {
+		b, ok := parseSTTYCC(syscall.VINTR, Caret(byte(i)))
+		if !ok || b != byte(i) {
+			t.Errorf("parseSTTYCC(Caret(%#x)) got: %#x, %t want: %#x, true", i, b, ok, i)
+		}
+	}
+}
+
+// TestCellSize tests the character cell pixel size calculations.
+func TestCellSize(t *testing.T) {
+	tests := []struct {
+		wz       Winsize
+		wpx, hpx int
+		ok       bool
+	}{
+		{Winsize{WsRow: 24, WsCol: 80, WsXpixel: 640, WsYpixel: 480}, 8, 20, true},
+		{Winsize{WsRow: 50, WsCol: 200, WsXpixel: 1810, WsYpixel: 1012}, 9, 20, true},
+		{Winsize{WsRow: 24, WsCol: 80}, 0, 0, false},
+		{Winsize{WsXpixel: 640, WsYpixel: 480}, 0, 0, false},
+		{Winsize{WsRow: 24, WsCol: 80, WsXpixel: 40, WsYpixel: 480}, 0, 0, false},
+	}
+	for _, tst := range tests {
+		wpx, hpx, ok := tst.wz.CellSize()
+		if wpx != tst.wpx || hpx != tst.hpx || ok != tst.ok {
+			t.Errorf("%+v.CellSize() got: %d, %d, %t want: %d, %d, %t", tst.wz, wpx, hpx, ok, tst.wpx, tst.hpx, tst.ok)
+		}
+	}
+	wz := Winsize{WsRow: 24, WsCol: 80, WsXpixel: 640, WsYpixel: 480}
+	for _, tst := range []struct {
+		px, py     int
+		cols, rows int
+	}{
+		{0, 0, 0, 0},
+		{8, 20, 1, 1},
+		{9, 21, 2, 2},
+		{640, 480, 80, 24},
+		{100, 50, 13, 3},
+	} {
+		cols, rows, ok := CellsForPixels(wz, tst.px, tst.py)
+		if cols != tst.cols || rows != tst.rows || !ok {
+			t.Errorf("CellsForPixels(%d, %d) got: %d, %d, %t want: %d, %d, true", tst.px, tst.py, cols, rows, ok, tst.cols, tst.rows)
+		}
+	}
+	if _, _, ok := CellsForPixels(Winsize{WsRow: 24, WsCol: 80}, 100, 100); ok {
+		t.Error("CellsForPixels without pixel sizes got ok: true want: false")
+	}
+}
+
+// TestSetSlaveRaw tests setting the Slave raw and cooked.
+func TestSetSlaveRaw(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	if err := tty.SetSlaveRaw(); err != nil {
+		t.Fatalf("SetSlaveRaw failed: %v", err)
+	}
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if err := testraw(tr, "TestSetSlaveRaw"); err != nil {
+		t.Errorf("SetSlaveRaw failed: %v", err)
+	}
+	tty.Master.Write([]byte("a"))
+	if b, err := ReadByteTimeout(tty.Slave, time.Second); err != nil || b != 'a' {
+		t.Errorf("Slave read in raw mode got: %q, %v want: 'a', <nil>", b, err)
+	}
+	if err := tty.SetSlaveCooked(); err != nil {
+		t.Fatalf("SetSlaveCooked failed: %v", err)
+	}
+	if tr, err = Attr(tty.Slave); err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if err := testcook(tr, "TestSetSlaveRaw"); err != nil {
+		t.Errorf("SetSlaveCooked failed: %v", err)
+	}
+	tty.Master.Write([]byte("b"))
+	if _, err := ReadByteTimeout(tty.Slave, 20*time.Millisecond); err != ErrTimeout {
+		t.Errorf("Slave read without a full line in cooked mode got: %v want: %v", err, ErrTimeout)
+	}
+	tty.Slave.Close()
+	if err := tty.SetSlaveRaw(); err == nil {
+		t.Error("SetSlaveRaw on closed Slave got: <nil> want: error")
+	}
+}
+
+// TestAttrInto tests reading the attributes into an existing Termios.
+func TestAttrInto(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	want, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	got := Termios{Lflag: syscall.ECHO, Wz: Winsize{WsRow: 24, WsCol: 80}}
+	if err := AttrInto(tty.Slave, &got); err != nil {
+		t.Fatalf("AttrInto failed: %v", err)
+	}
+	want.Wz = got.Wz
+	if got != want {
+		t.Errorf("AttrInto got: %+v want: %+v", got, want)
+	}
+	nf, err := donormfile("TestAttrInto")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if err := AttrInto(nf, &got); err == nil {
+		t.Error("AttrInto on a regular file got: <nil> want: error")
+	}
+	if got != want {
+		t.Errorf("AttrInto changed t on error got: %+v want: %+v", got, want)
+	}
+}
+
+// TestCopyAttr tests copying the attributes and size of one terminal to another.
+func TestCopyAttr(t *testing.T) {
+	src, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer src.Close()
+	dst, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer dst.Close()
+	want, err := Attr(src.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	want.Raw()
+	want.Cc[syscall.VINTR] = 7
+	want.Wz = Winsize{WsRow: 33, WsCol: 99, WsXpixel: 990, WsYpixel: 660}
+	if err := want.Set(src.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := want.Setwinsz(src.Slave); err != nil {
+		t.Fatalf("Setwinsz failed: %v", err)
+	}
+	if err := CopyAttr(dst.Slave, src.Slave); err != nil {
+		t.Fatalf("CopyAttr failed: %v", err)
+	}
+	got, err := Attr(dst.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if err := got.Winsz(dst.Slave); err != nil {
+		t.Fatalf("Winsz failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("CopyAttr got: %v want: %v", got, want)
+	}
+	f, err := donormfile("TestCopyAttr")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer f.Close()
+	if err := CopyAttr(dst.Slave, f); err == nil || !strings.Contains(err.Error(), "not a tty") {
+		t.Errorf("CopyAttr from a file got: %v want: not a tty", err)
+	}
+}
+
+// TestWaitReadable tests waiting for Master output without reading it.
+func TestWaitReadable(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	if ok, err := tty.WaitReadable(20 * time.Millisecond); ok || err != nil {
+		t.Errorf("WaitReadable with no output got: %t, %v want: false, <nil>", ok, err)
+	}
+	tty.Slave.Write([]byte("x"))
+	if ok, err := tty.WaitReadable(time.Second); !ok || err != nil {
+		t.Errorf("WaitReadable with output got: %t, %v want: true, <nil>", ok, err)
+	}
+	if ok, err := tty.WaitReadable(0); !ok || err != nil {
+		t.Errorf("WaitReadable again got: %t, %v want: true, <nil>", ok, err)
+	}
+	if b, err := tty.ReadByte(); b != 'x' || err != nil {
+		t.Errorf("ReadByte after WaitReadable got: %q, %v want: 'x', <nil>", b, err)
+	}
+	tty.Close()
+	if _, err := tty.WaitReadable(0); !errors.Is(err, ErrPTYClosed) {
+		t.Errorf("WaitReadable after Close got: %v want: %v", err, ErrPTYClosed)
+	}
+}
+
+// TestSetBlocking tests toggling O_NONBLOCK on the Master.
+func TestSetBlocking(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	nonblock := func() bool {
+		rc, err := tty.Master.SyscallConn()
+		if err != nil {
+			t.Fatalf("SyscallConn failed: %v", err)
+		}
+		var fl int
+		rc.Control(func(fd uintptr) {
+			fl, err = unix.FcntlInt(fd, unix.F_GETFL, 0)
+		})
+		if err != nil {
+			t.Fatalf("F_GETFL failed: %v", err)
+		}
+		return fl&unix.O_NONBLOCK != 0
+	}
+	for _, blocking := range []bool{true, false} {
+		if err := SetBlocking(tty.Master, blocking); err != nil {
+			t.Errorf("SetBlocking(%t) failed: %v", blocking, err)
+		}
+		if got := nonblock(); got == blocking {
+			t.Errorf("SetBlocking(%t) O_NONBLOCK got: %t want: %t", blocking, got, !blocking)
+		}
+	}
+	tty.Close()
+	if err := SetBlocking(tty.Master, true); err == nil {
+		t.Error("SetBlocking on a closed file got: <nil> want: error")
+	}
+}
+
+// TestHasInput tests checking for typeahead.
+func TestHasInput(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	if ok, err := HasInput(tty.Slave); ok || err != nil {
+		t.Errorf("HasInput with nothing typed got: %t, %v want: false, <nil>", ok, err)
+	}
+	tty.Master.Write([]byte("x"))
+	for end := time.Now().Add(time.Second); time.Now().Before(end); time.Sleep(time.Millisecond) {
+		if ok, _ := HasInput(tty.Slave); ok {
+			break
+		}
+	}
+	if ok, err := HasInput(tty.Slave); !ok || err != nil {
+		t.Errorf("HasInput with typeahead got: %t, %v want: true, <nil>", ok, err)
+	}
+	if b, err := GetChar(tty.Slave); b != 'x' || err != nil {
+		t.Errorf("GetChar after HasInput got: %q, %v want: 'x', <nil>", b, err)
+	}
+	if ok, err := HasInput(tty.Slave); ok || err != nil {
+		t.Errorf("HasInput after reading got: %t, %v want: false, <nil>", ok, err)
+	}
+}
+
+// TestFPrint tests newlines are translated only when the terminal does not.
+func TestFPrint(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	read := func(n int) string {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(tty.Master, b); err != nil {
+			t.Fatalf("Reading Master failed: %v", err)
+		}
+		return string(b)
+	}
+	want := "a\r\nb\r\nc\r\n"
+	if n, err := FPrint(tty.Slave, "a\nb\r\nc\n"); n != len(want) || err != nil {
+		t.Errorf("FPrint raw got: %d, %v want: %d, <nil>", n, err, len(want))
+	}
+	if got := read(len(want)); got != want {
+		t.Errorf("FPrint raw got: %q want: %q", got, want)
+	}
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	tr.Oflag |= syscall.OPOST | syscall.ONLCR
+	if err := tr.Set(tty.Slave); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if n, err := FPrint(tty.Slave, "a\nb\n"); n != 4 || err != nil {
+		t.Errorf("FPrint cooked got: %d, %v want: 4, <nil>", n, err)
+	}
+	if got := read(6); got != "a\r\nb\r\n" {
+		t.Errorf("FPrint cooked got: %q want: %q", got, "a\r\nb\r\n")
+	}
+	allocs := testing.AllocsPerRun(20, func() { FPrint(tty.Slave, "line\n") })
+	if allocs != 0 {
+		t.Errorf("FPrint cooked allocations got: %v want: 0", allocs)
+	}
+}
+
+// TestTeeMaster tests the Master reader copying to a transcript.
+func TestTeeMaster(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	var log strings.Builder
+	r := tty.TeeMaster(&log)
+	tty.Slave.Write([]byte("hello"))
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(r, b); err != nil || string(b) != "hello" {
+		t.Errorf("TeeMaster read got: %q, %v want: %q, <nil>", b, err, "hello")
+	}
+	if log.String() != "hello" {
+		t.Errorf("TeeMaster transcript got: %q want: %q", log.String(), "hello")
+	}
+}
+
+// TestSetOutputNewline tests the output newline modes.
+func TestSetOutputNewline(t *testing.T) {
+	tests := []struct {
+		mode OutputNLMode
+		want uint32
+	}{
+		{OutputLF, syscall.OPOST},
+		{OutputCRLF, syscall.OPOST | syscall.ONLCR},
+		{OutputCR, syscall.OPOST | syscall.OCRNL},
+	}
+	for _, tst := range tests {
+		var tr Termios
+		tr.Oflag = syscall.OPOST | syscall.ONLCR | syscall.OCRNL | syscall.ONOCR | syscall.ONLRET | syscall.OFILL
+		tr.SetOutputNewline(tst.mode)
+		if tr.Oflag != tst.want|syscall.OFILL {
+			t.Errorf("SetOutputNewline(%d) Oflag got: %#x want: %#x", tst.mode, tr.Oflag, tst.want|syscall.OFILL)
+		}
+	}
+	// The mapping as seen from the Master.
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	for _, tst := range []struct {
+		mode OutputNLMode
+		want string
+	}{
+		{OutputCRLF, "a\r\nb\r"},
+		{OutputCR, "a\nb\n"},
+	} {
+		tr, err := Attr(tty.Slave)
+		if err != nil {
+			t.Fatalf("Attr failed: %v", err)
+		}
+		tr.SetOutputNewline(tst.mode)
+		if err := tr.Set(tty.Slave); err != nil {
+			t.Fatalf("Set failed: %v", err)
+		}
+		tty.Slave.Write([]byte("a\nb\r"))
+		got := make([]byte, len(tst.want))
+		if _, err := io.ReadFull(tty.Master, got); err != nil || string(got) != tst.want {
+			t.Errorf("SetOutputNewline(%d) Master got: %q, %v want: %q", tst.mode, got, err, tst.want)
+		}
+	}
+}
+
+// TestSpeedString tests decoding the B* speed codes.
+func TestSpeedString(t *testing.T) {
+	tests := []struct {
+		code uint32
+		want string
+	}{
+		{unix.B0, "0"},
+		{unix.B50, "50"},
+		{unix.B75, "75"},
+		{unix.B110, "110"},
+		{unix.B134, "134"},
+		{unix.B150, "150"},
+		{unix.B200, "200"},
+		{unix.B300, "300"},
+		{unix.B600, "600"},
+		{unix.B1200, "1200"},
+		{unix.B1800, "1800"},
+		{unix.B2400, "2400"},
+		{unix.B4800, "4800"},
+		{unix.B9600, "9600"},
+		{unix.B19200, "19200"},
+		{unix.B38400, "38400"},
+		{unix.B57600, "57600"},
+		{unix.B115200, "115200"},
+		{unix.B230400, "230400"},
+		{unix.B460800, "460800"},
+		{unix.B500000, "500000"},
+		{unix.B576000, "576000"},
+		{unix.B921600, "921600"},
+		{unix.B1000000, "1000000"},
+		{unix.B1152000, "1152000"},
+		{unix.B1500000, "1500000"},
+		{unix.B2000000, "2000000"},
+		{unix.B2500000, "2500000"},
+		{unix.B3000000, "3000000"},
+		{unix.B3500000, "3500000"},
+		{unix.B4000000, "4000000"},
+		{unix.BOTHER, "custom"},
+	}
+	for _, tst := range tests {
+		tr := Termios{Ospeed: tst.code, Cflag: unix.B50}
+		if got := tr.SpeedString(); got != tst.want && tst.code != 0 {
+			t.Errorf("SpeedString(Ospeed %#o) got: %q want: %q", tst.code, got, tst.want)
+		}
+		tr = Termios{Cflag: tst.code | syscall.CS8 | syscall.CREAD}
+		if got := tr.SpeedString(); got != tst.want {
+			t.Errorf("SpeedString(Cflag %#o) got: %q want: %q", tst.code, got, tst.want)
+		}
+	}
+	if got := (&Termios{Ospeed: 0o17}).SpeedString(); got != "38400" {
+		t.Errorf("SpeedString(Ospeed %#o) got: %q want: %q", 0o17, got, "38400")
+	}
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	tr, err := Attr(tty.Slave)
+	if err != nil {
+		t.Fatalf("Attr failed: %v", err)
+	}
+	if got := tr.SpeedString(); got != "38400" {
+		t.Errorf("SpeedString of a new PTY got: %q want: %q", got, "38400")
+	}
+}
+
+// TestCharReader tests the buffered byte reads.
+func TestCharReader(t *testing.T) {
+	tty := rawPTY(t)
+	defer tty.Close()
+	cr := NewCharReader(tty.Slave)
+	tty.Master.Write([]byte("abc"))
+	for _, want := range []byte("abc") {
+		if b, err := cr.Get(); b != want || err != nil {
+			t.Errorf("Get got: %q, %v want: %q, <nil>", b, err, want)
+		}
+	}
+	if n := cr.Buffered(); n != 0 {
+		t.Errorf("Buffered got: %d want: 0", n)
+	}
+	tty.Master.Write([]byte("de"))
+	if b, err := cr.Get(); b != 'd' || err != nil {
+		t.Errorf("Get got: %q, %v want: 'd', <nil>", b, err)
+	}
+	if n := cr.Buffered(); n != 1 {
+		t.Errorf("Buffered got: %d want: 1", n)
+	}
+	tty.Master.Close()
+	if b, err := cr.Get(); b != 'e' || err != nil {
+		t.Errorf("Get got: %q, %v want: 'e', <nil>", b, err)
+	}
+	if _, err := cr.Get(); err == nil {
+		t.Error("Get after Master close got: <nil> want: error")
+	}
+}
+
+// BenchmarkCharReader measures Get, it should not allocate.
+func BenchmarkCharReader(b *testing.B) {
+	f, err := os.Open("/dev/zero")
+	if err != nil {
+		b.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	cr := NewCharReader(f)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := cr.Get(); err != nil {
+			b.Fatalf("Get failed: %v", err)
+		}
+	}
+}
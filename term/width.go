@@ -0,0 +1,501 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultTabWidth is the tab width of DisplayWidth and LineReader.
+const DefaultTabWidth = 8
+
+// DisplayWidth returns the number of terminal columns s takes up when printed.
+//
+// East Asian Wide and Fullwidth characters, eg. CJK and most emoji, take up two columns,
+// combining marks, format characters and control characters none and everything else one.
+// Tabs move on to the next multiple of DefaultTabWidth, counted from the start of s.
+// Escape sequences, eg. the SGR color codes from this package, are skipped.
+//
+// The wide characters are those of Unicode 15.0.0 East Asian Width, zero width
+// ones are based on the categories of the unicode package.
+func DisplayWidth(s string) int {
+	return DisplayWidthTab(s, DefaultTabWidth)
+}
+
+// DisplayWidthTab is DisplayWidth with tabs tabWidth columns apart.
+// A tabWidth <= 0 uses DefaultTabWidth.
+func DisplayWidthTab(s string, tabWidth int) int {
+	if tabWidth <= 0 {
+		tabWidth = DefaultTabWidth
+	}
+	return displayEnd(s, 0, tabWidth)
+}
+
+// displayEnd returns the column s ends at when printed starting at column col.
+func displayEnd(s string, col, tabWidth int) int {
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			i += escapeLen(s[i:])
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		col = advance(col, r, tabWidth)
+		i += n
+	}
+	return col
+}
+
+// advance returns the column after printing r at column col, a tab going on to
+// the next multiple of tabWidth.
+func advance(col int, r rune, tabWidth int) int {
+	if r == '\t' {
+		return col + tabWidth - col%tabWidth
+	}
+	return col + runeWidth(r)
+}
+
+// expandTabs replaces the tabs in s with spaces up to the next multiple of
+// tabWidth, s being printed starting at column col.
+func expandTabs(s string, col, tabWidth int) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case esc:
+			n := escapeLen(s[i:])
+			b.WriteString(s[i : i+n])
+			i += n
+			continue
+		case '\t':
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+n])
+		col += runeWidth(r)
+		i += n
+	}
+	return b.String()
+}
+
+// Wrap wraps s into lines of at most width display columns, see DisplayWidth.
+//
+// Lines are broken on spaces where possible, words longer than width are broken
+// wherever they hit the end of the line. Newlines in s always start a new line.
+// Tabs go to the next multiple of DefaultTabWidth, a tab that doesn't fit breaks
+// the line like a space.
+// Colors and other SGR modes active at the end of a line are reset there and set
+// again at the beginning of the next line, so every line can be printed on its own.
+//
+// A width <= 0 only splits s on the newlines.
+func Wrap(s string, width int) []string {
+	paras := strings.Split(s, "\n")
+	if width <= 0 {
+		return paras
+	}
+	w := wrapper{width: width}
+	for _, para := range paras {
+		for i, word := range strings.Split(para, " ") {
+			if i > 0 && w.col > 0 {
+				if displayEnd(word, w.col+1, DefaultTabWidth) <= w.width {
+					w.line.WriteByte(' ')
+					w.col++
+				} else {
+					w.newline()
+				}
+			}
+			w.word(word)
+		}
+		w.newline()
+	}
+	return w.lines
+}
+
+// Truncate cuts s down to fit in width display columns, see DisplayWidth.
+// If s needs cutting ellipsis, eg. "...", is appended taking up part of the width.
+// Multibyte characters and escape sequences are never split and colors or other
+// SGR modes left on where s is cut are reset before the ellipsis.
+//
+// If width can't even hold the ellipsis s is cut to width with no ellipsis.
+func Truncate(s string, width int, ellipsis string) string {
+	if DisplayWidth(s) <= width {
+		return s
+	}
+	room := width - DisplayWidth(ellipsis)
+	if room < 0 {
+		room, ellipsis = width, ""
+	}
+	var res strings.Builder
+	col := 0
+	var sgr sgrState
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			n := escapeLen(s[i:])
+			if params, ok := sgrParams(s[i : i+n]); ok {
+				sgr.apply(params)
+			}
+			res.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(s[i:])
+		next := advance(col, r, DefaultTabWidth)
+		if next > room {
+			break
+		}
+		col = next
+		res.WriteString(s[i : i+n])
+		i += n
+	}
+	if sgr.on() {
+		res.WriteString(CSI + NoMode + "m")
+	}
+	res.WriteString(ellipsis)
+	return res.String()
+}
+
+// wrapper keeps track of the state while wrapping lines.
+type wrapper struct {
+	width int
+	lines []string
+	line  strings.Builder
+	col   int      // col display width of the current line
+	sgr   sgrState // sgr SGR modes on at the current position
+}
+
+// word adds word to the current line, breaking it up if it does not fit.
+func (w *wrapper) word(word string) {
+	for i := 0; i < len(word); {
+		if word[i] == esc {
+			n := escapeLen(word[i:])
+			w.escape(word[i : i+n])
+			i += n
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(word[i:])
+		next := advance(w.col, r, DefaultTabWidth)
+		if r == '\t' && next > w.width {
+			// Dropped at the break like a space.
+			if w.col > 0 {
+				w.newline()
+			}
+			i += n
+			continue
+		}
+		if w.col > 0 && next > w.width {
+			w.newline()
+			next = advance(0, r, DefaultTabWidth)
+		}
+		w.line.WriteString(word[i : i+n])
+		w.col = next
+		i += n
+	}
+}
+
+// escape adds the escape sequence seq, tracking the SGR state.
+func (w *wrapper) escape(seq string) {
+	w.line.WriteString(seq)
+	if params, ok := sgrParams(seq); ok {
+		w.sgr.apply(params)
+	}
+}
+
+// newline finishes the current line and starts a new one.
+func (w *wrapper) newline() {
+	if w.sgr.on() {
+		w.line.WriteString(CSI + NoMode + "m")
+	}
+	w.lines = append(w.lines, w.line.String())
+	w.line.Reset()
+	w.col = 0
+	w.line.WriteString(w.sgr.seq())
+}
+
+// sgrState keeps track of the SGR modes on, the foreground and background colors
+// apart from the other attributes as FgDefault and BgDefault only turn off the color.
+type sgrState struct {
+	fg, bg string   // fg, bg the color parameters, "" for the default
+	attrs  []string // attrs the other parameters, eg. "1" for bold
+}
+
+// sgrOff are the SGR parameters turning attributes off and the ones they turn off.
+var sgrOff = map[string][]string{
+	"22": {"1", "2"},
+	"23": {"3"},
+	"24": {"4", "21"},
+	"25": {"5", "6"},
+	"27": {"7"},
+	"28": {"8"},
+	"29": {"9"},
+	"55": {"53"},
+	"59": {"58"},
+}
+
+// apply changes the state by the SGR params.
+func (st *sgrState) apply(params string) {
+	ps := strings.Split(params, ";")
+	for i := 0; i < len(ps); i++ {
+		p := ps[i]
+		base := sgrBase(p)
+		// The extended colors, 38;5;<n> and 38;2;<r>;<g>;<b>, take the following params.
+		if (p == "38" || p == "48" || p == "58") && i+1 < len(ps) {
+			n := 0
+			switch ps[i+1] {
+			case "5":
+				n = 2
+			case "2":
+				n = 4
+			}
+			p = strings.Join(ps[i:min(i+1+n, len(ps))], ";")
+			i += n
+		}
+		switch {
+		case base == "" || base == NoMode || base == "00":
+			*st = sgrState{}
+		case base == FgDefault:
+			st.fg = ""
+		case base == BgDefault:
+			st.bg = ""
+		case base == "38" || sgrColor(base, "3") || sgrColor(base, "9"):
+			st.fg = p
+		case base == "48" || sgrColor(base, "4") || sgrColor(base, "10"):
+			st.bg = p
+		case sgrOff[base] != nil:
+			st.attrs = slices.DeleteFunc(st.attrs, func(a string) bool {
+				return slices.Contains(sgrOff[base], sgrBase(a))
+			})
+		default:
+			st.attrs = slices.DeleteFunc(st.attrs, func(a string) bool { return sgrBase(a) == base })
+			st.attrs = append(st.attrs, p)
+		}
+	}
+}
+
+// sgrBase returns the SGR parameter p without its sub-parameters or the arguments
+// of an extended color, eg. "4" for "4:3" and "58" for "58;5;1".
+func sgrBase(p string) string {
+	if i := strings.IndexAny(p, ":;"); i >= 0 {
+		return p[:i]
+	}
+	return p
+}
+
+// sgrColor returns true if p is one of the 8 colors following prefix, eg. 31 for "3".
+func sgrColor(p, prefix string) bool {
+	return len(p) == len(prefix)+1 && strings.HasPrefix(p, prefix) && p[len(prefix)] >= '0' && p[len(prefix)] <= '7'
+}
+
+// on returns true if any mode is on.
+func (st *sgrState) on() bool {
+	return st.fg != "" || st.bg != "" || len(st.attrs) > 0
+}
+
+// seq returns the SGR sequence turning the modes on, "" if none are.
+func (st *sgrState) seq() string {
+	if !st.on() {
+		return ""
+	}
+	ps := append([]string(nil), st.attrs...)
+	for _, c := range []string{st.fg, st.bg} {
+		if c != "" {
+			ps = append(ps, c)
+		}
+	}
+	return CSI + strings.Join(ps, ";") + "m"
+}
+
+// sgrParams returns the parameters of seq if it's an SGR (CSI ... m) sequence.
+func sgrParams(seq string) (string, bool) {
+	if len(seq) < 3 || !strings.HasPrefix(seq, CSI) || seq[len(seq)-1] != 'm' {
+		return "", false
+	}
+	return seq[2 : len(seq)-1], true
+}
+
+// runeWidth returns the number of columns r takes up.
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20, r >= 0x7f && r < 0xa0:
+		return 0
+	case r == 0xad:
+		// Soft hyphen, a format char but terminals show it.
+		return 1
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf), r >= 0x1160 && r <= 0x11ff:
+		// Combining marks, format chars and Hangul medial vowels / final consonants.
+		return 0
+	case unicode.Is(wideTable, r):
+		return 2
+	}
+	return 1
+}
+
+// escapeLen returns the length of the escape sequence s starts with.
+// Unterminated sequences run to the end of s.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return len(s)
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case ']', 'P', 'X', '^', '_':
+		for i := 2; i < len(s); i++ {
+			if s[i] == bel && s[1] == ']' {
+				return i + 1
+			}
+			if s[i] == '\\' && s[i-1] == esc {
+				return i + 1
+			}
+		}
+	case 'O':
+		if len(s) > 2 {
+			return 3
+		}
+	default:
+		return 2
+	}
+	return len(s)
+}
+
+// wideTable East Asian Wide (W) and Fullwidth (F) characters from Unicode 15.0.0.
+var wideTable = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x1100, Hi: 0x115F, Stride: 1},
+		{Lo: 0x231A, Hi: 0x231B, Stride: 1},
+		{Lo: 0x2329, Hi: 0x232A, Stride: 1},
+		{Lo: 0x23E9, Hi: 0x23EC, Stride: 1},
+		{Lo: 0x23F0, Hi: 0x23F0, Stride: 1},
+		{Lo: 0x23F3, Hi: 0x23F3, Stride: 1},
+		{Lo: 0x25FD, Hi: 0x25FE, Stride: 1},
+		{Lo: 0x2614, Hi: 0x2615, Stride: 1},
+		{Lo: 0x2648, Hi: 0x2653, Stride: 1},
+		{Lo: 0x267F, Hi: 0x267F, Stride: 1},
+		{Lo: 0x2693, Hi: 0x2693, Stride: 1},
+		{Lo: 0x26A1, Hi: 0x26A1, Stride: 1},
+		{Lo: 0x26AA, Hi: 0x26AB, Stride: 1},
+		{Lo: 0x26BD, Hi: 0x26BE, Stride: 1},
+		{Lo: 0x26C4, Hi: 0x26C5, Stride: 1},
+		{Lo: 0x26CE, Hi: 0x26CE, Stride: 1},
+		{Lo: 0x26D4, Hi: 0x26D4, Stride: 1},
+		{Lo: 0x26EA, Hi: 0x26EA, Stride: 1},
+		{Lo: 0x26F2, Hi: 0x26F3, Stride: 1},
+		{Lo: 0x26F5, Hi: 0x26F5, Stride: 1},
+		{Lo: 0x26FA, Hi: 0x26FA, Stride: 1},
+		{Lo: 0x26FD, Hi: 0x26FD, Stride: 1},
+		{Lo: 0x2705, Hi: 0x2705, Stride: 1},
+		{Lo: 0x270A, Hi: 0x270B, Stride: 1},
+		{Lo: 0x2728, Hi: 0x2728, Stride: 1},
+		{Lo: 0x274C, Hi: 0x274C, Stride: 1},
+		{Lo: 0x274E, Hi: 0x274E, Stride: 1},
+		{Lo: 0x2753, Hi: 0x2755, Stride: 1},
+		{Lo: 0x2757, Hi: 0x2757, Stride: 1},
+		{Lo: 0x2795, Hi: 0x2797, Stride: 1},
+		{Lo: 0x27B0, Hi: 0x27B0, Stride: 1},
+		{Lo: 0x27BF, Hi: 0x27BF, Stride: 1},
+		{Lo: 0x2B1B, Hi: 0x2B1C, Stride: 1},
+		{Lo: 0x2B50, Hi: 0x2B50, Stride: 1},
+		{Lo: 0x2B55, Hi: 0x2B55, Stride: 1},
+		{Lo: 0x2E80, Hi: 0x2E99, Stride: 1},
+		{Lo: 0x2E9B, Hi: 0x2EF3, Stride: 1},
+		{Lo: 0x2F00, Hi: 0x2FD5, Stride: 1},
+		{Lo: 0x2FF0, Hi: 0x2FFB, Stride: 1},
+		{Lo: 0x3000, Hi: 0x303E, Stride: 1},
+		{Lo: 0x3041, Hi: 0x3096, Stride: 1},
+		{Lo: 0x3099, Hi: 0x30FF, Stride: 1},
+		{Lo: 0x3105, Hi: 0x312F, Stride: 1},
+		{Lo: 0x3131, Hi: 0x318E, Stride: 1},
+		{Lo: 0x3190, Hi: 0x31E3, Stride: 1},
+		{Lo: 0x31F0, Hi: 0x321E, Stride: 1},
+		{Lo: 0x3220, Hi: 0x3247, Stride: 1},
+		{Lo: 0x3250, Hi: 0x4DBF, Stride: 1},
+		{Lo: 0x4E00, Hi: 0xA48C, Stride: 1},
+		{Lo: 0xA490, Hi: 0xA4C6, Stride: 1},
+		{Lo: 0xA960, Hi: 0xA97C, Stride: 1},
+		{Lo: 0xAC00, Hi: 0xD7A3, Stride: 1},
+		{Lo: 0xF900, Hi: 0xFAFF, Stride: 1},
+		{Lo: 0xFE10, Hi: 0xFE19, Stride: 1},
+		{Lo: 0xFE30, Hi: 0xFE52, Stride: 1},
+		{Lo: 0xFE54, Hi: 0xFE66, Stride: 1},
+		{Lo: 0xFE68, Hi: 0xFE6B, Stride: 1},
+		{Lo: 0xFF01, Hi: 0xFF60, Stride: 1},
+		{Lo: 0xFFE0, Hi: 0xFFE6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x16FE0, Hi: 0x16FE4, Stride: 1},
+		{Lo: 0x16FF0, Hi: 0x16FF1, Stride: 1},
+		{Lo: 0x17000, Hi: 0x187F7, Stride: 1},
+		{Lo: 0x18800, Hi: 0x18CD5, Stride: 1},
+		{Lo: 0x18D00, Hi: 0x18D08, Stride: 1},
+		{Lo: 0x1AFF0, Hi: 0x1AFF3, Stride: 1},
+		{Lo: 0x1AFF5, Hi: 0x1AFFB, Stride: 1},
+		{Lo: 0x1AFFD, Hi: 0x1AFFE, Stride: 1},
+		{Lo: 0x1B000, Hi: 0x1B122, Stride: 1},
+		{Lo: 0x1B132, Hi: 0x1B132, Stride: 1},
+		{Lo: 0x1B150, Hi: 0x1B152, Stride: 1},
+		{Lo: 0x1B155, Hi: 0x1B155, Stride: 1},
+		{Lo: 0x1B164, Hi: 0x1B167, Stride: 1},
+		{Lo: 0x1B170, Hi: 0x1B2FB, Stride: 1},
+		{Lo: 0x1F004, Hi: 0x1F004, Stride: 1},
+		{Lo: 0x1F0CF, Hi: 0x1F0CF, Stride: 1},
+		{Lo: 0x1F18E, Hi: 0x1F18E, Stride: 1},
+		{Lo: 0x1F191, Hi: 0x1F19A, Stride: 1},
+		{Lo: 0x1F200, Hi: 0x1F202, Stride: 1},
+		{Lo: 0x1F210, Hi: 0x1F23B, Stride: 1},
+		{Lo: 0x1F240, Hi: 0x1F248, Stride: 1},
+		{Lo: 0x1F250, Hi: 0x1F251, Stride: 1},
+		{Lo: 0x1F260, Hi: 0x1F265, Stride: 1},
+		{Lo: 0x1F300, Hi: 0x1F320, Stride: 1},
+		{Lo: 0x1F32D, Hi: 0x1F335, Stride: 1},
+		{Lo: 0x1F337, Hi: 0x1F37C, Stride: 1},
+		{Lo: 0x1F37E, Hi: 0x1F393, Stride: 1},
+		{Lo: 0x1F3A0, Hi: 0x1F3CA, Stride: 1},
+		{Lo: 0x1F3CF, Hi: 0x1F3D3, Stride: 1},
+		{Lo: 0x1F3E0, Hi: 0x1F3F0, Stride: 1},
+		{Lo: 0x1F3F4, Hi: 0x1F3F4, Stride: 1},
+		{Lo: 0x1F3F8, Hi: 0x1F43E, Stride: 1},
+		{Lo: 0x1F440, Hi: 0x1F440, Stride: 1},
+		{Lo: 0x1F442, Hi: 0x1F4FC, Stride: 1},
+		{Lo: 0x1F4FF, Hi: 0x1F53D, Stride: 1},
+		{Lo: 0x1F54B, Hi: 0x1F54E, Stride: 1},
+		{Lo: 0x1F550, Hi: 0x1F567, Stride: 1},
+		{Lo: 0x1F57A, Hi: 0x1F57A, Stride: 1},
+		{Lo: 0x1F595, Hi: 0x1F596, Stride: 1},
+		{Lo: 0x1F5A4, Hi: 0x1F5A4, Stride: 1},
+		{Lo: 0x1F5FB, Hi: 0x1F64F, Stride: 1},
+		{Lo: 0x1F680, Hi: 0x1F6C5, Stride: 1},
+		{Lo: 0x1F6CC, Hi: 0x1F6CC, Stride: 1},
+		{Lo: 0x1F6D0, Hi: 0x1F6D2, Stride: 1},
+		{Lo: 0x1F6D5, Hi: 0x1F6D7, Stride: 1},
+		{Lo: 0x1F6DC, Hi: 0x1F6DF, Stride: 1},
+		{Lo: 0x1F6EB, Hi: 0x1F6EC, Stride: 1},
+		{Lo: 0x1F6F4, Hi: 0x1F6FC, Stride: 1},
+		{Lo: 0x1F7E0, Hi: 0x1F7EB, Stride: 1},
+		{Lo: 0x1F7F0, Hi: 0x1F7F0, Stride: 1},
+		{Lo: 0x1F90C, Hi: 0x1F93A, Stride: 1},
+		{Lo: 0x1F93C, Hi: 0x1F945, Stride: 1},
+		{Lo: 0x1F947, Hi: 0x1F9FF, Stride: 1},
+		{Lo: 0x1FA70, Hi: 0x1FA7C, Stride: 1},
+		{Lo: 0x1FA80, Hi: 0x1FA88, Stride: 1},
+		{Lo: 0x1FA90, Hi: 0x1FABD, Stride: 1},
+		{Lo: 0x1FABF, Hi: 0x1FAC5, Stride: 1},
+		{Lo: 0x1FACE, Hi: 0x1FADB, Stride: 1},
+		{Lo: 0x1FAE0, Hi: 0x1FAE8, Stride: 1},
+		{Lo: 0x1FAF0, Hi: 0x1FAF8, Stride: 1},
+		{Lo: 0x20000, Hi: 0x2FFFD, Stride: 1},
+		{Lo: 0x30000, Hi: 0x3FFFD, Stride: 1},
+	},
+}
@@ -0,0 +1,165 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestDisplayWidth tests the display width calculations.
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"Hello", 5},
+		{"日本語", 6},
+		{"한국어", 6},
+		{"ﾊﾝｶｸ", 4},
+		{"ＡＢ", 4},
+		{"e\u0301", 1},
+		{"a\u200bb", 2},
+		{"😀", 2},
+		{"tab\x07", 3},
+		{Green("green").String(), 5},
+		{NewColorRGB("rgb", 1, 2, 3).String(), 3},
+		{"\033]0;title\a日本", 4},
+		{"\033[1;3", 0},
+		{"\t", 8},
+		{"a\tb", 9},
+		{"1234567\t", 8},
+		{"12345678\t", 16},
+		{"日本語\t\t", 16},
+		{Green("a").String() + "\tb", 9},
+	}
+	for _, tst := range tests {
+		if got := DisplayWidth(tst.in); got != tst.want {
+			t.Errorf("DisplayWidth(%q) got: %d want: %d", tst.in, got, tst.want)
+		}
+	}
+}
+
+// TestDisplayWidthTab tests the display width with other tab widths.
+func TestDisplayWidthTab(t *testing.T) {
+	tests := []struct {
+		in   string
+		tw   int
+		want int
+	}{
+		{"\t", 4, 4},
+		{"abc\td", 4, 5},
+		{"abcd\td", 4, 9},
+		{"\t\t", 2, 4},
+		{"a\t", 1, 2},
+		{"a\t", 0, 8},
+		{"a\t", -1, 8},
+	}
+	for _, tst := range tests {
+		if got := DisplayWidthTab(tst.in, tst.tw); got != tst.want {
+			t.Errorf("DisplayWidthTab(%q, %d) got: %d want: %d", tst.in, tst.tw, got, tst.want)
+		}
+	}
+}
+
+// TestExpandTabs tests replacing tabs with spaces.
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		in   string
+		col  int
+		want string
+	}{
+		{"abc", 0, "abc"},
+		{"\tx", 0, "    x"},
+		{"\tx", 2, "  x"},
+		{"ab\tc\t", 0, "ab  c   "},
+		{"日\tx", 1, "日 x"},
+		{"\033[32m\tx", 0, "\033[32m    x"},
+	}
+	for _, tst := range tests {
+		if got := expandTabs(tst.in, tst.col, 4); got != tst.want {
+			t.Errorf("expandTabs(%q, %d, 4) got: %q want: %q", tst.in, tst.col, got, tst.want)
+		}
+	}
+}
+
+// TestWrap tests wrapping text to a column width.
+func TestWrap(t *testing.T) {
+	red := Red("hello world").String()
+	tests := []struct {
+		in    string
+		width int
+		want  []string
+	}{
+		{"the quick brown fox", 10, []string{"the quick", "brown fox"}},
+		{"the quick brown fox", 9, []string{"the quick", "brown fox"}},
+		{"the quick brown fox", 0, []string{"the quick brown fox"}},
+		{"short\nlines here", 20, []string{"short", "lines here"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"a abcdefghij", 4, []string{"a", "abcd", "efgh", "ij"}},
+		{"日本語テキスト", 6, []string{"日本語", "テキス", "ト"}},
+		{"日本語テキスト", 5, []string{"日本", "語テ", "キス", "ト"}},
+		{"漢字 かな", 4, []string{"漢字", "かな"}},
+		{"", 10, []string{""}},
+		{red, 5, []string{"\033[31mhello\033[0m", "\033[31mworld\033[39m"}},
+		{"\033[1mbold\033[0m text", 4, []string{"\033[1mbold\033[0m", "text"}},
+		{"\033[1m\033[31mbold red\033[39m bold", 8, []string{"\033[1m\033[31mbold red\033[39m\033[0m", "\033[1mbold\033[0m"}},
+		{"\033[4;38;5;1;48;2;1;2;3mab\033[24mcd", 2, []string{"\033[4;38;5;1;48;2;1;2;3mab\033[24m\033[0m", "\033[38;5;1;48;2;1;2;3mcd\033[0m"}},
+		{"ab\tcd ef", 6, []string{"ab", "cd ef"}},
+		{"a\tb c", 10, []string{"a\tb", "c"}},
+		{"a bcdefgh\tx", 12, []string{"a", "bcdefgh\tx"}},
+	}
+	for _, tst := range tests {
+		got := Wrap(tst.in, tst.width)
+		if !reflect.DeepEqual(got, tst.want) {
+			t.Errorf("Wrap(%q, %d) got: %q want: %q", tst.in, tst.width, got, tst.want)
+		}
+		if tst.width <= 0 {
+			continue
+		}
+		for _, l := range got {
+			if DisplayWidth(l) > tst.width {
+				t.Errorf("Wrap(%q, %d) line %q wider than %d", tst.in, tst.width, l, tst.width)
+			}
+		}
+	}
+}
+
+// TestTruncate tests cutting strings down to a column width.
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in       string
+		width    int
+		ellipsis string
+		want     string
+	}{
+		{"short", 10, "...", "short"},
+		{"exactly10!", 10, "...", "exactly10!"},
+		{"this is too long", 10, "...", "this is..."},
+		{"this is too long", 10, "…", "this is t…"},
+		{"this is too long", 2, "...", "th"},
+		{"日本語テキスト", 7, "..", "日本.."},
+		{"日本語テキスト", 6, "", "日本語"},
+		{"日本語テキスト", 5, "", "日本"},
+		{Red("red and long").String(), 6, "...", "\033[31mred\033[0m..."},
+		{Red("red").String() + " plain text", 7, "~", "\033[31mred\033[39m pl~"},
+		{"\033[1;31mbold\033[39m still bold", 7, "~", "\033[1;31mbold\033[39m s\033[0m~"},
+		{"\033[44;31mblue\033[49m on red", 7, "~", "\033[44;31mblue\033[49m o\033[0m~"},
+		{"a\tb", 5, "...", "a..."},
+		{"a\tb", 10, "...", "a\tb"},
+		{"ab\tcdef", 10, "~", "ab\tc~"},
+		{"", 0, "...", ""},
+	}
+	for _, tst := range tests {
+		got := Truncate(tst.in, tst.width, tst.ellipsis)
+		if got != tst.want {
+			t.Errorf("Truncate(%q, %d, %q) got: %q want: %q", tst.in, tst.width, tst.ellipsis, got, tst.want)
+		}
+		if DisplayWidth(got) > tst.width {
+			t.Errorf("Truncate(%q, %d, %q) got: %q wider than %d", tst.in, tst.width, tst.ellipsis, got, tst.width)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// WinsizeEvent is a window size reported by WinsizeEvents.
+type WinsizeEvent struct {
+	Winsize
+	Gen uint64 // Gen counts the sizes sent, starting at 1 for the size when watching started
+}
+
+// WinsizeEvents sends the window size of f on the returned channel, first the current
+// size and then the new one every time the program gets SIGWINCH, until ctx is done
+// and the channel is closed.
+//
+// Only the latest size is kept when the receiver falls behind, an older size not yet
+// received is replaced, so a renderer taking the next event after each frame never
+// draws at a size that's already outdated. Gen goes up with every size sent, when
+// drawing happens elsewhere a frame started for an older Gen than the last received
+// is stale and can be skipped.
+//
+//	events := term.WinsizeEvents(ctx, os.Stdout)
+//	for ev := range events {
+//		redraw(ev.Winsize, ev.Gen)
+//	}
+//
+// Sizes that can't be read, eg. f is not a tty, are not sent.
+func WinsizeEvents(ctx context.Context, f *os.File) <-chan WinsizeEvent {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	ch := make(chan WinsizeEvent, 1)
+	go func() {
+		defer close(ch)
+		defer signal.Stop(sigs)
+		var gen uint64
+		for {
+			var t Termios
+			if err := t.Winsz(f); err == nil {
+				gen++
+				sendLatest(ch, WinsizeEvent{Winsize: t.Wz, Gen: gen})
+			}
+			select {
+			case <-sigs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// sendLatest sends ev on ch, replacing an event not yet received.
+// Only works with a single sender and a buffer of one.
+func sendLatest(ch chan WinsizeEvent, ev WinsizeEvent) {
+	for {
+		select {
+		case ch <- ev:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// WinsizeTracker keeps the last window size observed for a terminal and reports
+// how much it changed since, for reflowing incrementally instead of laying out
+// everything again on every resize.
+//
+//	wt, err := term.NewWinsizeTracker(os.Stdout)
+//	for ev := range term.WinsizeEvents(ctx, os.Stdout) {
+//		if drows, dcols := wt.Update(ev.Winsize); dcols != 0 {
+//			rewrap()
+//		} else if drows != 0 {
+//			scroll(drows)
+//		}
+//	}
+//
+// A WinsizeTracker is safe to use from multiple goroutines.
+type WinsizeTracker struct {
+	f    *os.File
+	mu   sync.Mutex
+	last Winsize
+}
+
+// NewWinsizeTracker returns a WinsizeTracker for the terminal f, the current
+// size of f being the first size observed.
+func NewWinsizeTracker(f *os.File) (*WinsizeTracker, error) {
+	var t Termios
+	if err := t.Winsz(f); err != nil {
+		return nil, err
+	}
+	return &WinsizeTracker{f: f, last: t.Wz}, nil
+}
+
+// Size returns the last size observed.
+func (wt *WinsizeTracker) Size() Winsize {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	return wt.last
+}
+
+// Delta reads the current size of the terminal and returns how many rows and columns
+// it grew by since the last size observed, negative when it shrank, making it the
+// last size observed. 0, 0 is returned when the size can't be read.
+func (wt *WinsizeTracker) Delta() (drows, dcols int) {
+	var t Termios
+	if err := t.Winsz(wt.f); err != nil {
+		return 0, 0
+	}
+	return wt.Update(t.Wz)
+}
+
+// Update is Delta for a size already read, eg. sent by WinsizeEvents.
+func (wt *WinsizeTracker) Update(ws Winsize) (drows, dcols int) {
+	wt.mu.Lock()
+	defer wt.mu.Unlock()
+	drows, dcols = int(ws.WsRow)-int(wt.last.WsRow), int(ws.WsCol)-int(wt.last.WsCol)
+	wt.last = ws
+	return drows, dcols
+}
@@ -0,0 +1,105 @@
+// Copyright 2015 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package term
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// setWinsize sets the window size of the Slave and signals the test itself, the
+// kernel only sends SIGWINCH to the foreground process group of the PTY.
+func setWinsize(t *testing.T, tty *PTY, rows, cols uint16) {
+	t.Helper()
+	tr := Termios{Wz: Winsize{WsRow: rows, WsCol: cols}}
+	if err := tr.Setwinsz(tty.Slave); err != nil {
+		t.Fatalf("Setwinsz failed: %v", err)
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGWINCH)
+}
+
+// TestWinsizeEvents tests the sizes and generations sent.
+func TestWinsizeEvents(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	setWinsize(t, tty, 24, 80)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := WinsizeEvents(ctx, tty.Slave)
+	next := func() WinsizeEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("no WinsizeEvent within a second")
+		}
+		return WinsizeEvent{}
+	}
+	if ev := next(); ev.WsRow != 24 || ev.WsCol != 80 || ev.Gen != 1 {
+		t.Errorf("WinsizeEvents first got: %+v want: 24x80 Gen 1", ev)
+	}
+	setWinsize(t, tty, 30, 100)
+	if ev := next(); ev.WsRow != 30 || ev.WsCol != 100 || ev.Gen != 2 {
+		t.Errorf("WinsizeEvents resize got: %+v want: 30x100 Gen 2", ev)
+	}
+	cancel()
+	for range events {
+	}
+}
+
+// TestSendLatest tests an event not received is replaced by the newer one.
+func TestSendLatest(t *testing.T) {
+	ch := make(chan WinsizeEvent, 1)
+	sendLatest(ch, WinsizeEvent{Gen: 1})
+	sendLatest(ch, WinsizeEvent{Gen: 2})
+	if ev := <-ch; ev.Gen != 2 {
+		t.Errorf("sendLatest got: Gen %d want: Gen 2", ev.Gen)
+	}
+	select {
+	case ev := <-ch:
+		t.Errorf("sendLatest got: extra %+v want: nothing", ev)
+	default:
+	}
+}
+
+// TestWinsizeTracker tests the size changes reported.
+func TestWinsizeTracker(t *testing.T) {
+	tty, err := OpenPTY()
+	if err != nil {
+		t.Fatalf("OpenPTY failed: %v", err)
+	}
+	defer tty.Close()
+	setWinsize(t, tty, 24, 80)
+	wt, err := NewWinsizeTracker(tty.Slave)
+	if err != nil {
+		t.Fatalf("NewWinsizeTracker failed: %v", err)
+	}
+	if dr, dc := wt.Delta(); dr != 0 || dc != 0 {
+		t.Errorf("Delta unchanged got: %d, %d want: 0, 0", dr, dc)
+	}
+	setWinsize(t, tty, 30, 70)
+	if dr, dc := wt.Delta(); dr != 6 || dc != -10 {
+		t.Errorf("Delta got: %d, %d want: 6, -10", dr, dc)
+	}
+	if dr, dc := wt.Update(Winsize{WsRow: 20, WsCol: 100}); dr != -10 || dc != 30 {
+		t.Errorf("Update got: %d, %d want: -10, 30", dr, dc)
+	}
+	if got := wt.Size(); got.WsRow != 20 || got.WsCol != 100 {
+		t.Errorf("Size got: %dx%d want: 20x100", got.WsRow, got.WsCol)
+	}
+	nf, err := donormfile("TestWinsizeTracker")
+	if err != nil {
+		t.Fatalf("donormfile failed: %v", err)
+	}
+	defer nf.Close()
+	if _, err := NewWinsizeTracker(nf); err == nil {
+		t.Error("NewWinsizeTracker on a normal file got: <nil> want: error")
+	}
+}